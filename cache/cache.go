@@ -0,0 +1,18 @@
+// Package cache stores encoded responses for a limited time, either in
+// process or shared by every server instance.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps values by key until they expire. A miss is not an error.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete drops the entry under key, if there is one.
+	Delete(ctx context.Context, key string) error
+	// Clear drops every entry, for when the data behind them changes.
+	Clear(ctx context.Context) error
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStore is a Store local to this process. It holds at most maxEntries
+// values and evicts the least recently used one to make room.
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List // front is the most recently used
+	entries    map[string]*list.Element
+	now        func() time.Time
+}
+
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*entry)
+	if !s.now().Before(e.expires) {
+		s.remove(el)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires := s.now().Add(ttl)
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*entry)
+		e.value, e.expires = value, expires
+		s.order.MoveToFront(el)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(&entry{key: key, value: value, expires: expires})
+	for s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Clear(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.order.Init()
+	clear(s.entries)
+	return nil
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*entry).key)
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoStore is a Store shared by every server instance using the same
+// collection, so that one instance's invalidation reaches them all.
+// Entries expire through a TTL index on expiresAt; since that runs only
+// about once a minute, Get also ignores entries past their time.
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoStore(collection *mongo.Collection) *MongoStore {
+	return &MongoStore{collection: collection}
+}
+
+func (s *MongoStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: key},
+		{Key: "expiresAt", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	}
+	var doc struct {
+		Value []byte `bson:"value"`
+	}
+	err := s.collection.FindOne(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return doc.Value, true, nil
+}
+
+func (s *MongoStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	doc := bson.D{
+		{Key: "_id", Value: key},
+		{Key: "value", Value: value},
+		{Key: "expiresAt", Value: time.Now().Add(ttl)},
+	}
+	_, err := s.collection.ReplaceOne(ctx, bson.D{{Key: "_id", Value: key}}, doc, options.Replace().SetUpsert(true))
+	// A concurrent Set of a new key can race on the _id index; the other
+	// instance stored the same value.
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *MongoStore) Delete(ctx context.Context, key string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: key}})
+	return err
+}
+
+func (s *MongoStore) Clear(ctx context.Context) error {
+	_, err := s.collection.DeleteMany(ctx, bson.D{})
+	return err
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy is how long clients and shared caches such as CDNs may reuse a
+// response: fresh for MaxAge, then served stale for up to
+// StaleWhileRevalidate more while they revalidate it in the background.
+type Policy struct {
+	MaxAge               time.Duration
+	StaleWhileRevalidate time.Duration
+}
+
+// ParsePolicy parses policies written as "<max-age>[:<stale-while-revalidate>]",
+// such as "1m" or "1m:10m".
+func ParsePolicy(s string) (Policy, error) {
+	maxAge, swr, hasSWR := strings.Cut(strings.TrimSpace(s), ":")
+	var p Policy
+	var err error
+	if p.MaxAge, err = time.ParseDuration(maxAge); err != nil || p.MaxAge < 0 {
+		return Policy{}, fmt.Errorf("invalid cache policy %q: max-age must be a non-negative duration", s)
+	}
+	if hasSWR {
+		if p.StaleWhileRevalidate, err = time.ParseDuration(swr); err != nil || p.StaleWhileRevalidate < 0 {
+			return Policy{}, fmt.Errorf("invalid cache policy %q: stale-while-revalidate must be a non-negative duration", s)
+		}
+	}
+	return p, nil
+}
+
+// Header returns the Cache-Control header value for the policy.
+func (p Policy) Header() string {
+	h := "public, max-age=" + strconv.Itoa(int(p.MaxAge.Seconds()))
+	if p.StaleWhileRevalidate > 0 {
+		h += ", stale-while-revalidate=" + strconv.Itoa(int(p.StaleWhileRevalidate.Seconds()))
+	}
+	return h
+}
@@ -2,39 +2,226 @@ package config
 
 import (
 	"os"
+	"strings"
+	"time"
+
+	"github.com/tahsin005/codercat-server/cache"
+	"github.com/tahsin005/codercat-server/ratelimit"
+)
+
+// defaultRateLimits keeps the search endpoint, a $text query and the most
+// expensive public read, well below the generous limit applied to
+// everything else. Search needs the text_search index that the migrations
+// create, and matches whole words rather than parts of them. Both limits
+// are per client address, which behind a proxy relies on
+// TRUSTED_PROXY_HOPS; otherwise they cap the whole site.
+const defaultRateLimits = "*=20/1s:40@ip; GET /blogs/search=2/1s:10@ip"
+
+// defaultContentSecurityPolicy suits a JSON API whose only HTML is the
+// self-contained privacy pages, which use inline styles and post back to
+// themselves.
+const defaultContentSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'; base-uri 'none'"
+
+// defaultBodyLimits allows larger uploads only for the CSV import.
+const defaultBodyLimits = "*=1MB; POST /admin/subscribers/import=10MB"
+
+// defaultCachePolicies lets clients and CDNs reuse blog responses for a
+// minute and keep serving them for a few more while they revalidate.
+const defaultCachePolicies = "*=1m:5m"
+
+// defaultCompressionTypes are the text formats the server sends.
+const defaultCompressionTypes = "application/json, application/problem+json, text/html, text/plain, text/css, text/csv"
+
+// Environments select defaults suited to local development or to a
+// deployment.
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
 )
 
 type Config struct {
-	MongoURI                 string
-	MongoDBName              string
-	MongoCollNameBlogs       string
-	MongoCollNameSubscribers string
-	Port                     string
-	SMTPEmail                string
-	SMTPPassword             string
-	SMTPHost                 string
-	SMTPPort                 string
-	BaseURL                  string
+	Environment               string
+	MailEnabled               bool
+	DatabaseDriver            string
+	DatabaseURL               string
+	MongoURI                  string
+	MongoDBName               string
+	MongoCollNameBlogs        string
+	MongoCollNameSubscribers  string
+	MongoCollNameSends        string
+	MongoCollNameSuppressions string
+	MongoCollNameConsents     string
+	Port                      string
+	SMTPEmail                 string
+	SMTPPassword              string
+	SMTPHost                  string
+	SMTPPort                  string
+	BaseURL                   string
+	TrashRetention            time.Duration
+	TrashPurgeInterval        time.Duration
+	ReadingWPM                int
+	AutoMigrate               bool
+	CanonicalizeGmail         bool // changing it changes the suppression hashes, un-suppressing erased addresses
+	SuppressionKey            string
+	AdminToken                string
+	TrustedProxyHops          int
+	ConsentTextVersion        string
+	PriorConsentVersions      []string
+	MongoCollNameRateLimits   string
+	RateLimitStore            string
+	SubscribeIPLimit          ratelimit.Limit
+	SubscribeDomainLimit      ratelimit.Limit
+	BlockDisposableEmails     bool
+	PowEnabled                bool
+	PowDifficulty             int
+	PowSecret                 string
+	PowTTL                    time.Duration
+	RateLimitPolicies         []ratelimit.Policy
+	RateLimitAPIKeys          []string
+	CacheStore                string
+	MongoCollNameCache        string
+	CacheTTL                  time.Duration
+	CacheMaxEntries           int
+	CachePolicies             map[string]cache.Policy
+	CORSAllowedOrigins        []string
+	CORSAllowedHeaders        []string
+	CORSExposedHeaders        []string
+	CORSAllowCredentials      bool
+	CORSMaxAge                time.Duration
+	ContentSecurityPolicy     string
+	HSTSMaxAge                time.Duration
+	ReferrerPolicy            string
+	FrameOptions              string
+	BodyLimits                map[string]int64
+	CompressionEncodings      []string
+	CompressionMinSize        int64
+	CompressionTypes          []string
+	ReadHeaderTimeout         time.Duration
+	ReadTimeout               time.Duration
+	WriteTimeout              time.Duration
+	IdleTimeout               time.Duration
+	ShutdownTimeout           time.Duration
+	ShutdownDelay             time.Duration
+	HealthCheckTimeout        time.Duration
+	HealthCheckSMTP           bool
+	MetricsToken              string
+	TracingExporter           string
+	TracingServiceName        string
+	TracingSampleRatio        float64
+	LogLevel                  string
+	LogFormat                 string
+
+	settings []setting
 }
 
+// LoadConfig reads the configuration from the environment and, when
+// CONFIG_FILE names one, a YAML or TOML file whose keys are the lower-case
+// environment variable names. Environment variables override the file, and
+// any key may instead be read from the file named by its _FILE variant.
+//
+// Every problem found is reported at once as Errors. The returned Config
+// is still set when only validation failed, so that it can be printed.
 func LoadConfig() (*Config, error) {
-	return &Config{
-		MongoURI:                 getEnv("MONGO_URI", "mongodb://localhost:27017"),
-		MongoDBName:              getEnv("MONGO_DB_NAME", "codercat"),
-		MongoCollNameBlogs:       getEnv("MONGO_COLLECTION_NAME_BLOG", "blogs"),
-		MongoCollNameSubscribers: getEnv("MONGO_COLLECTION_NAME_SUBSCRIBERS", "subscribers"),
-		Port:                     getEnv("PORT", "8080"),
-		SMTPEmail:                getEnv("SMTP_EMAIL", ""),
-		SMTPPassword:             getEnv("SMTP_PASSWORD", ""),
-		SMTPHost:                 getEnv("SMTP_HOST", "smtp.gmail.com"),
-		SMTPPort:                 getEnv("SMTP_PORT", "587"),
-		BaseURL:                  getEnv("BASE_URL", "https://codercat-server.onrender.com"),
-	}, nil
-}
+	l, err := newLoader(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return nil, err
+	}
+
+	env := l.string("APP_ENV", EnvDevelopment)
+	production := env == EnvProduction
+	port := l.string("PORT", "8080")
+	baseURLDefault := ""
+	if !production {
+		baseURLDefault = "http://localhost:" + port
+	}
+	// Deployments sit behind a load balancer or platform proxy, without
+	// which every client would appear to share its address.
+	proxyHopsDefault := 0
+	if production {
+		proxyHopsDefault = 1
+	}
+	driver := l.string("DATABASE_DRIVER", "mongo")
+	databaseURLDefault := ""
+	if driver == "sqlite" {
+		databaseURLDefault = "codercat.db"
+	}
 
-func getEnv(key, defaultVal string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+	cfg := &Config{
+		Environment:               env,
+		MailEnabled:               l.bool("MAIL_ENABLED", true),
+		DatabaseDriver:            driver,
+		DatabaseURL:               l.string("DATABASE_URL", databaseURLDefault),
+		MongoURI:                  l.string("MONGO_URI", "mongodb://localhost:27017"),
+		MongoDBName:               l.string("MONGO_DB_NAME", "codercat"),
+		MongoCollNameBlogs:        l.string("MONGO_COLLECTION_NAME_BLOG", "blogs"),
+		MongoCollNameSubscribers:  l.string("MONGO_COLLECTION_NAME_SUBSCRIBERS", "subscribers"),
+		MongoCollNameSends:        l.string("MONGO_COLLECTION_NAME_SENDS", "sends"),
+		MongoCollNameSuppressions: l.string("MONGO_COLLECTION_NAME_SUPPRESSIONS", "suppressions"),
+		MongoCollNameConsents:     l.string("MONGO_COLLECTION_NAME_CONSENTS", "consents"),
+		Port:                      port,
+		SMTPEmail:                 l.string("SMTP_EMAIL", ""),
+		SMTPPassword:              l.secret("SMTP_PASSWORD"),
+		SMTPHost:                  l.string("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:                  l.string("SMTP_PORT", "587"),
+		BaseURL:                   strings.TrimSuffix(l.string("BASE_URL", baseURLDefault), "/"),
+		TrashRetention:            l.duration("TRASH_RETENTION", 30*24*time.Hour),
+		TrashPurgeInterval:        l.duration("TRASH_PURGE_INTERVAL", time.Hour),
+		ReadingWPM:                l.int("READING_WPM", 230),
+		AutoMigrate:               l.bool("AUTO_MIGRATE", true),
+		CanonicalizeGmail:         l.bool("CANONICALIZE_GMAIL", false),
+		SuppressionKey:            l.secret("SUPPRESSION_KEY"),
+		AdminToken:                l.secret("ADMIN_TOKEN"),
+		TrustedProxyHops:          l.int("TRUSTED_PROXY_HOPS", proxyHopsDefault),
+		ConsentTextVersion:        l.string("CONSENT_TEXT_VERSION", "1"),
+		PriorConsentVersions:      l.list("CONSENT_TEXT_PRIOR_VERSIONS", ""),
+		MongoCollNameRateLimits:   l.string("MONGO_COLLECTION_NAME_RATE_LIMITS", "rate_limits"),
+		RateLimitStore:            l.string("RATE_LIMIT_STORE", "memory"),
+		SubscribeIPLimit:          l.limit("SUBSCRIBE_IP_LIMIT", "5/1h"),
+		SubscribeDomainLimit:      l.limit("SUBSCRIBE_DOMAIN_LIMIT", "off"),
+		BlockDisposableEmails:     l.bool("BLOCK_DISPOSABLE_EMAILS", true),
+		PowEnabled:                l.bool("POW_ENABLED", false),
+		PowDifficulty:             l.int("POW_DIFFICULTY", 18),
+		PowSecret:                 l.secret("POW_SECRET"),
+		PowTTL:                    l.duration("POW_TTL", 5*time.Minute),
+		RateLimitPolicies:         l.policies("RATE_LIMITS", defaultRateLimits),
+		RateLimitAPIKeys:          l.secretList("RATE_LIMIT_API_KEYS"),
+		CacheStore:                l.string("CACHE_STORE", "memory"),
+		MongoCollNameCache:        l.string("MONGO_COLLECTION_NAME_CACHE", "cache"),
+		CacheTTL:                  l.duration("CACHE_TTL", time.Minute),
+		CacheMaxEntries:           l.int("CACHE_MAX_ENTRIES", 1000),
+		CachePolicies:             l.cachePolicies("CACHE_POLICIES", defaultCachePolicies),
+		CORSAllowedOrigins:        l.list("CORS_ALLOWED_ORIGINS", ""),
+		CORSAllowedHeaders:        l.list("CORS_ALLOWED_HEADERS", "Content-Type, Authorization, X-API-Key"),
+		CORSExposedHeaders:        l.list("CORS_EXPOSED_HEADERS", "Content-Disposition, Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy"),
+		CORSAllowCredentials:      l.bool("CORS_ALLOW_CREDENTIALS", false),
+		CORSMaxAge:                l.duration("CORS_MAX_AGE", 10*time.Minute),
+		ContentSecurityPolicy:     l.string("CONTENT_SECURITY_POLICY", defaultContentSecurityPolicy),
+		HSTSMaxAge:                l.duration("HSTS_MAX_AGE", 180*24*time.Hour),
+		ReferrerPolicy:            l.string("REFERRER_POLICY", "no-referrer"),
+		FrameOptions:              l.string("FRAME_OPTIONS", "DENY"),
+		BodyLimits:                l.bodyLimits("BODY_LIMITS", defaultBodyLimits),
+		CompressionEncodings:      l.list("COMPRESSION_ENCODINGS", "br, zstd, gzip"),
+		CompressionMinSize:        l.byteSize("COMPRESSION_MIN_SIZE", "1KB"),
+		CompressionTypes:          l.list("COMPRESSION_TYPES", defaultCompressionTypes),
+		ReadHeaderTimeout:         l.duration("READ_HEADER_TIMEOUT", 5*time.Second),
+		ReadTimeout:               l.duration("READ_TIMEOUT", 30*time.Second),
+		WriteTimeout:              l.duration("WRITE_TIMEOUT", 60*time.Second),
+		IdleTimeout:               l.duration("IDLE_TIMEOUT", 120*time.Second),
+		ShutdownTimeout:           l.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		ShutdownDelay:             l.duration("SHUTDOWN_DELAY", 0),
+		HealthCheckTimeout:        l.duration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		HealthCheckSMTP:           l.bool("HEALTH_CHECK_SMTP", false),
+		MetricsToken:              l.secret("METRICS_TOKEN"),
+		TracingExporter:           l.string("TRACING_EXPORTER", "none"),
+		TracingServiceName:        l.string("OTEL_SERVICE_NAME", "codercat-server"),
+		TracingSampleRatio:        l.float("TRACING_SAMPLE_RATIO", 1),
+		LogLevel:                  l.string("LOG_LEVEL", "info"),
+		LogFormat:                 l.string("LOG_FORMAT", "json"),
+	}
+	l.unused()
+	cfg.settings = l.settings
+	if errs := append(l.errs, cfg.validate()...); len(errs) > 0 {
+		return cfg, errs
 	}
-	return defaultVal
+	return cfg, nil
 }
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/tahsin005/codercat-server/cache"
+	"github.com/tahsin005/codercat-server/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// Where a setting's value came from, as shown by Config.Print.
+const (
+	sourceDefault = "default"
+	sourceEnv     = "env"
+	sourceFile    = "config file"
+)
+
+// setting is the resolved value of one configuration key.
+type setting struct {
+	key    string
+	value  string
+	source string
+	secret bool
+}
+
+// fileValue is a value read from the config file. Lists are kept apart so
+// each key can join them with its own separator.
+type fileValue struct {
+	scalar string
+	list   []string
+	isList bool
+}
+
+// loader resolves configuration keys from the environment, secret files and
+// the config file, recording every value it hands out and every problem it
+// finds along the way.
+type loader struct {
+	file     map[string]fileValue
+	used     map[string]bool
+	settings []setting
+	errs     Errors
+}
+
+func newLoader(path string) (*loader, error) {
+	l := &loader{file: map[string]fileValue{}, used: map[string]bool{}}
+	if path == "" {
+		return l, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var raw map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file %q: want .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if err := flatten(l.file, "", raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// flatten stores the values in raw under their upper-case keys, joining
+// the keys of nested tables with underscores so that "smtp: {host: x}"
+// sets SMTP_HOST.
+func flatten(dst map[string]fileValue, prefix string, raw map[string]any) error {
+	for k, v := range raw {
+		key := strings.ToUpper(prefix + k)
+		switch v := v.(type) {
+		case map[string]any:
+			if err := flatten(dst, key+"_", v); err != nil {
+				return err
+			}
+		case []any:
+			list := make([]string, len(v))
+			for i, item := range v {
+				s, err := scalar(item)
+				if err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				list[i] = s
+			}
+			dst[key] = fileValue{list: list, isList: true}
+		default:
+			s, err := scalar(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			dst[key] = fileValue{scalar: s}
+		}
+	}
+	return nil
+}
+
+func scalar(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", v)
+	}
+}
+
+// lookup returns the value of key and where it came from. An environment
+// variable wins over the config file, and either may name a file holding
+// the value instead, as Docker secrets are mounted, by appending _FILE to
+// the key. Lists from the config file are joined with sep.
+func (l *loader) lookup(key, sep string) (string, string, bool) {
+	fileKey := key + "_FILE"
+	// The config file may set a key the environment overrides, which is
+	// not a typo.
+	l.used[key], l.used[fileKey] = true, true
+	value, inEnv := os.LookupEnv(key)
+	path, inEnvFile := os.LookupEnv(fileKey)
+	if inEnv && inEnvFile {
+		l.errorf(key, "set either %s or %s, not both", key, fileKey)
+	}
+	switch {
+	case inEnv:
+		return value, sourceEnv, true
+	case inEnvFile:
+		return l.readSecret(key, path), fileKey, true
+	}
+
+	v, inFile := l.file[key]
+	fv, inFileFile := l.file[fileKey]
+	if inFile && inFileFile {
+		l.errorf(key, "set either %s or %s in the config file, not both", strings.ToLower(key), strings.ToLower(fileKey))
+	}
+	switch {
+	case inFile && v.isList:
+		return strings.Join(v.list, sep), sourceFile, true
+	case inFile:
+		return v.scalar, sourceFile, true
+	case inFileFile:
+		return l.readSecret(key, fv.scalar), sourceFile + " (" + strings.ToLower(fileKey) + ")", true
+	}
+	return "", sourceDefault, false
+}
+
+func (l *loader) readSecret(key, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.errorf(key, "failed to read secret file: %v", err)
+		return ""
+	}
+	return strings.TrimRight(string(data), "\r\n")
+}
+
+// value resolves key, falling back to defaultVal, and records the result.
+func (l *loader) value(key, sep, defaultVal string, secret bool) (string, bool) {
+	value, source, ok := l.lookup(key, sep)
+	if !ok {
+		value = defaultVal
+	}
+	l.settings = append(l.settings, setting{key: key, value: value, source: source, secret: secret})
+	return value, ok
+}
+
+func (l *loader) errorf(key, format string, args ...any) {
+	l.errs = append(l.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+// unused reports config file keys that no setting asked for, which are
+// most likely typos.
+func (l *loader) unused() {
+	var keys []string
+	for key := range l.file {
+		if !l.used[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		l.errs = append(l.errs, fmt.Errorf("%s: unknown setting in config file", strings.ToLower(key)))
+	}
+}
+
+func (l *loader) string(key, defaultVal string) string {
+	value, _ := l.value(key, ",", defaultVal, false)
+	return value
+}
+
+// secret is like string but the value is redacted by Config.Print.
+func (l *loader) secret(key string) string {
+	value, _ := l.value(key, ",", "", true)
+	return value
+}
+
+// list reads a comma separated list, dropping empty entries.
+func (l *loader) list(key, defaultVal string) []string {
+	value, _ := l.value(key, ",", defaultVal, false)
+	return splitList(value)
+}
+
+// secretList is like list but the value is redacted by Config.Print.
+func (l *loader) secretList(key string) []string {
+	value, _ := l.value(key, ",", "", true)
+	return splitList(value)
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (l *loader) duration(key string, defaultVal time.Duration) time.Duration {
+	value, ok := l.value(key, ",", defaultVal.String(), false)
+	if !ok {
+		return defaultVal
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		l.errorf(key, "invalid duration %q", value)
+		return defaultVal
+	}
+	return d
+}
+
+func (l *loader) int(key string, defaultVal int) int {
+	value, ok := l.value(key, ",", strconv.Itoa(defaultVal), false)
+	if !ok {
+		return defaultVal
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		l.errorf(key, "invalid integer %q", value)
+		return defaultVal
+	}
+	return n
+}
+
+func (l *loader) float(key string, defaultVal float64) float64 {
+	value, ok := l.value(key, ",", strconv.FormatFloat(defaultVal, 'g', -1, 64), false)
+	if !ok {
+		return defaultVal
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.errorf(key, "invalid number %q", value)
+		return defaultVal
+	}
+	return f
+}
+
+func (l *loader) bool(key string, defaultVal bool) bool {
+	value, ok := l.value(key, ",", strconv.FormatBool(defaultVal), false)
+	if !ok {
+		return defaultVal
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.errorf(key, "invalid boolean %q", value)
+		return defaultVal
+	}
+	return b
+}
+
+// limit reads a rate limit such as "5/1h". The value "off" disables the
+// limit.
+func (l *loader) limit(key, defaultVal string) ratelimit.Limit {
+	value, _ := l.value(key, ",", defaultVal, false)
+	if value == "off" {
+		return ratelimit.Limit{}
+	}
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		l.errorf(key, "%v", err)
+	}
+	return limit
+}
+
+// policies reads route rate limit policies in the format accepted by
+// ratelimit.ParsePolicies. An empty value disables route rate limiting.
+func (l *loader) policies(key, defaultVal string) []ratelimit.Policy {
+	value, _ := l.value(key, ";", defaultVal, false)
+	policies, err := ratelimit.ParsePolicies(value)
+	if err != nil {
+		l.errorf(key, "%v", err)
+	}
+	return policies
+}
+
+// bodyLimits reads request body limits written as "<route>=<size>",
+// separated by semicolons, where route is "*", a path template or a method
+// and path template and size is a byte count with an optional KB or MB
+// suffix.
+func (l *loader) bodyLimits(key, defaultVal string) map[string]int64 {
+	value, _ := l.value(key, ";", defaultVal, false)
+	limits, err := parseBodyLimits(value)
+	if err != nil {
+		l.errorf(key, "%v", err)
+	}
+	return limits
+}
+
+func parseBodyLimits(s string) (map[string]int64, error) {
+	limits := make(map[string]int64)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, size, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid body limit %q: want <route>=<size>", entry)
+		}
+		n, err := parseByteSize(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body limit %q: %w", entry, err)
+		}
+		limits[ratelimit.NormalizeRoute(route)] = n
+	}
+	return limits, nil
+}
+
+// byteSize reads a byte count with an optional KB or MB suffix.
+func (l *loader) byteSize(key, defaultVal string) int64 {
+	value, _ := l.value(key, ",", defaultVal, false)
+	n, err := parseByteSize(value)
+	if err != nil {
+		l.errorf(key, "%v", err)
+	}
+	return n
+}
+
+// cachePolicies reads response cache policies written as
+// "<route>=<policy>", separated by semicolons, with routes as for
+// bodyLimits and policies in the format accepted by cache.ParsePolicy.
+func (l *loader) cachePolicies(key, defaultVal string) map[string]cache.Policy {
+	value, _ := l.value(key, ";", defaultVal, false)
+	policies, err := parseCachePolicies(value)
+	if err != nil {
+		l.errorf(key, "%v", err)
+	}
+	return policies
+}
+
+func parseCachePolicies(s string) (map[string]cache.Policy, error) {
+	policies := make(map[string]cache.Policy)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid cache policy %q: want <route>=<policy>", entry)
+		}
+		policy, err := cache.ParsePolicy(spec)
+		if err != nil {
+			return nil, err
+		}
+		policies[ratelimit.NormalizeRoute(route)] = policy
+	}
+	return policies, nil
+}
+
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "MB"):
+		multiplier, s = 1<<20, strings.TrimSuffix(s, "MB")
+	case strings.HasSuffix(s, "KB"):
+		multiplier, s = 1<<10, strings.TrimSuffix(s, "KB")
+	case strings.HasSuffix(s, "B"):
+		s = strings.TrimSuffix(s, "B")
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("size must be a non-negative number of bytes, KB or MB")
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "REDACTED"
+
+// Print writes the effective configuration as a config file, noting where
+// each value came from. Secrets, and the passwords in MONGO_URI and
+// DATABASE_URL, are redacted.
+func (c *Config) Print(w io.Writer) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range c.settings {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: strings.ToLower(s.key)}
+		value := &yaml.Node{Kind: yaml.ScalarNode, Value: s.display(), LineComment: s.source}
+		// Quote every value so that numbers and booleans read back exactly
+		// as printed.
+		value.Style = yaml.DoubleQuotedStyle
+		doc.Content = append(doc.Content, key, value)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func (s setting) display() string {
+	if s.secret && s.value != "" {
+		return redacted
+	}
+	if s.key == "MONGO_URI" || s.key == "DATABASE_URL" {
+		if u, err := url.Parse(s.value); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), redacted)
+				return u.String()
+			}
+		}
+	}
+	return s.value
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors collects every problem found while loading the configuration.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = "  " + err.Error()
+	}
+	return "invalid configuration:\n" + strings.Join(msgs, "\n")
+}
+
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// validate checks settings that parsed but do not make sense, alone or
+// together.
+func (c *Config) validate() Errors {
+	var errs Errors
+	add := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		if !slices.Contains(allowed, value) {
+			add(key, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+		}
+	}
+	positive := func(key string, d time.Duration) {
+		if d <= 0 {
+			add(key, "must be positive, got %s", d)
+		}
+	}
+	nonNegative := func(key string, d time.Duration) {
+		if d < 0 {
+			add(key, "must not be negative, got %s", d)
+		}
+	}
+
+	oneOf("APP_ENV", c.Environment, EnvDevelopment, EnvProduction)
+	if n, err := strconv.Atoi(c.Port); err != nil || n < 1 || n > 65535 {
+		add("PORT", "must be a port number, got %q", c.Port)
+	}
+	if c.BaseURL == "" {
+		add("BASE_URL", "is required in production; set it to the public URL of the server")
+	} else if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("BASE_URL", "must be an absolute http or https URL, got %q", c.BaseURL)
+	}
+
+	oneOf("DATABASE_DRIVER", c.DatabaseDriver, "mongo", "sqlite", "postgres")
+	switch c.DatabaseDriver {
+	case "mongo":
+		if !strings.HasPrefix(c.MongoURI, "mongodb://") && !strings.HasPrefix(c.MongoURI, "mongodb+srv://") {
+			add("MONGO_URI", "must start with mongodb:// or mongodb+srv://")
+		}
+		if c.MongoDBName == "" {
+			add("MONGO_DB_NAME", "is required")
+		}
+	case "sqlite":
+		if c.DatabaseURL == "" {
+			add("DATABASE_URL", "is required when DATABASE_DRIVER is sqlite; set it to the database file")
+		}
+	case "postgres":
+		if !strings.HasPrefix(c.DatabaseURL, "postgres://") && !strings.HasPrefix(c.DatabaseURL, "postgresql://") {
+			add("DATABASE_URL", "must start with postgres:// or postgresql:// when DATABASE_DRIVER is postgres")
+		}
+	}
+
+	if c.MailEnabled {
+		if c.SMTPEmail == "" {
+			add("SMTP_EMAIL", "is required when MAIL_ENABLED is true")
+		}
+		if c.SMTPPassword == "" {
+			add("SMTP_PASSWORD", "is required when MAIL_ENABLED is true")
+		}
+		if c.SMTPHost == "" {
+			add("SMTP_HOST", "is required when MAIL_ENABLED is true")
+		}
+		if n, err := strconv.Atoi(c.SMTPPort); err != nil || n < 1 || n > 65535 {
+			add("SMTP_PORT", "must be a port number, got %q", c.SMTPPort)
+		}
+	} else if c.HealthCheckSMTP {
+		add("HEALTH_CHECK_SMTP", "requires MAIL_ENABLED")
+	}
+
+	nonNegative("TRASH_RETENTION", c.TrashRetention)
+	positive("TRASH_PURGE_INTERVAL", c.TrashPurgeInterval)
+	if c.ReadingWPM <= 0 {
+		add("READING_WPM", "must be positive, got %d", c.ReadingWPM)
+	}
+	if c.TrustedProxyHops < 0 {
+		add("TRUSTED_PROXY_HOPS", "must not be negative, got %d", c.TrustedProxyHops)
+	}
+
+	oneOf("RATE_LIMIT_STORE", c.RateLimitStore, "memory", "mongo")
+	if c.RateLimitStore == "mongo" && c.DatabaseDriver != "mongo" {
+		add("RATE_LIMIT_STORE", "mongo requires DATABASE_DRIVER to be mongo")
+	}
+	oneOf("CACHE_STORE", c.CacheStore, "memory", "mongo")
+	if c.CacheStore == "mongo" && c.DatabaseDriver != "mongo" {
+		add("CACHE_STORE", "mongo requires DATABASE_DRIVER to be mongo")
+	}
+	nonNegative("CACHE_TTL", c.CacheTTL)
+	if c.CacheMaxEntries <= 0 {
+		add("CACHE_MAX_ENTRIES", "must be positive, got %d", c.CacheMaxEntries)
+	}
+	if c.PowEnabled {
+		if c.PowDifficulty < 1 || c.PowDifficulty > 32 {
+			add("POW_DIFFICULTY", "must be between 1 and 32, got %d", c.PowDifficulty)
+		}
+		positive("POW_TTL", c.PowTTL)
+	}
+
+	if c.CORSAllowCredentials && slices.Contains(c.CORSAllowedOrigins, "*") {
+		add("CORS_ALLOW_CREDENTIALS", "cannot be combined with the wildcard origin in CORS_ALLOWED_ORIGINS")
+	}
+	nonNegative("CORS_MAX_AGE", c.CORSMaxAge)
+	nonNegative("HSTS_MAX_AGE", c.HSTSMaxAge)
+
+	for _, encoding := range c.CompressionEncodings {
+		oneOf("COMPRESSION_ENCODINGS", encoding, "br", "zstd", "gzip")
+	}
+
+	nonNegative("READ_HEADER_TIMEOUT", c.ReadHeaderTimeout)
+	nonNegative("READ_TIMEOUT", c.ReadTimeout)
+	nonNegative("WRITE_TIMEOUT", c.WriteTimeout)
+	nonNegative("IDLE_TIMEOUT", c.IdleTimeout)
+	positive("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	nonNegative("SHUTDOWN_DELAY", c.ShutdownDelay)
+	positive("HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout)
+	if c.Environment == EnvProduction && c.MetricsToken == "" {
+		add("METRICS_TOKEN", "is required in production; /metrics exposes subscriber and post counts")
+	}
+	if c.Environment == EnvProduction && c.SuppressionKey == "" {
+		add("SUPPRESSION_KEY", "is required in production; it keys the hashes of erased addresses")
+	}
+
+	oneOf("TRACING_EXPORTER", c.TracingExporter, "none", "stdout", "otlp")
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		add("TRACING_SAMPLE_RATIO", "must be between 0 and 1, got %g", c.TracingSampleRatio)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		add("LOG_LEVEL", "must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	oneOf("LOG_FORMAT", strings.ToLower(c.LogFormat), "json", "text")
+	return errs
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tahsin005/codercat-server/config"
+)
+
+const configUsage = "usage: codercat-server config print"
+
+// runConfig implements the "config" subcommand. It runs before the
+// configuration is validated so that an invalid one can still be printed;
+// loadErr is what loading it reported.
+func runConfig(cfg *config.Config, loadErr error, args []string) error {
+	cmd := ""
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "print":
+		if cfg != nil {
+			if err := cfg.Print(os.Stdout); err != nil {
+				return err
+			}
+		}
+		return loadErr
+	default:
+		return fmt.Errorf("unknown config command %q\n%s", cmd, configUsage)
+	}
+}
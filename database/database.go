@@ -2,13 +2,19 @@ package database
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
 
 	"github.com/tahsin005/codercat-server/config"
+	"github.com/tahsin005/codercat-server/logging"
+	"github.com/tahsin005/codercat-server/tracing"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
 )
 
+// Database is the MongoDB driver, the default. SQLDatabase serves the
+// SQLite and PostgreSQL backends.
 type Database struct {
 	Client *mongo.Client
 	DB     *mongo.Database
@@ -16,20 +22,18 @@ type Database struct {
 
 func NewDatabase(cfg *config.Config) (*Database, error) {
 	if cfg.MongoURI == "" {
-		log.Fatal("Set your 'MONGO_URI' environment variable.")
+		return nil, errors.New("set your 'MONGO_URI' environment variable")
 	}
 
-	clientOptions := options.Client().ApplyURI(cfg.MongoURI)
+	clientOptions := options.Client().ApplyURI(cfg.MongoURI).SetMonitor(tracing.CommandMonitor())
 
 	client, err := mongo.Connect(clientOptions)
 	if err != nil {
-		log.Printf("Failed to connect to MongoDB Atlas: %v", err)
 		return nil, err
 	}
 
 	err = client.Ping(context.TODO(), nil)
 	if err != nil {
-		log.Printf("Failed to ping MongoDB Atlas: %v", err)
 		return nil, err
 	}
 
@@ -38,8 +42,13 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 	return &Database{Client: client, DB: db}, nil
 }
 
-func (d *Database) Disconnect() {
-	if err := d.Client.Disconnect(context.TODO()); err != nil {
-		log.Printf("Error disconnecting from MongoDB Atlas: %v", err)
+// Ping checks that the primary is reachable.
+func (d *Database) Ping(ctx context.Context) error {
+	return d.Client.Ping(ctx, readpref.Primary())
+}
+
+func (d *Database) Disconnect(ctx context.Context) {
+	if err := d.Client.Disconnect(ctx); err != nil {
+		slog.ErrorContext(ctx, "failed to disconnect from MongoDB Atlas", logging.Err(err))
 	}
 }
\ No newline at end of file
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/tahsin005/codercat-server/config"
+	"github.com/tahsin005/codercat-server/logging"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Dialect identifies the SQL database behind a SQLDatabase.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// sqlitePragmas enforce foreign keys, which SQLite leaves off by default,
+// and let readers continue while a write is in progress.
+var sqlitePragmas = []string{"foreign_keys(1)", "journal_mode(WAL)", "busy_timeout(5000)"}
+
+// SQLDatabase is the SQLite or PostgreSQL alternative to the Mongo
+// Database. Queries are written with ? placeholders and rewritten for the
+// dialect.
+type SQLDatabase struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+func NewSQLDatabase(cfg *config.Config) (*SQLDatabase, error) {
+	if cfg.DatabaseURL == "" {
+		return nil, errors.New("set your 'DATABASE_URL' environment variable")
+	}
+
+	dialect := Dialect(cfg.DatabaseDriver)
+	var db *sql.DB
+	var err error
+	switch dialect {
+	case SQLite:
+		db, err = sql.Open("sqlite", sqliteDSN(cfg.DatabaseURL))
+		if err != nil {
+			return nil, err
+		}
+		// SQLite allows a single writer, so one connection queues writes
+		// in the pool instead of failing them with SQLITE_BUSY.
+		db.SetMaxOpenConns(1)
+	case Postgres:
+		db, err = sql.Open("pgx", cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SQL driver %q", cfg.DatabaseDriver)
+	}
+
+	if err := db.PingContext(context.TODO()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLDatabase{DB: db, Dialect: dialect}, nil
+}
+
+// sqliteDSN turns a database file name, or a file: URI, into a DSN that
+// applies sqlitePragmas to every connection.
+func sqliteDSN(url string) string {
+	if !strings.HasPrefix(url, "file:") {
+		url = "file:" + url
+	}
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	for _, pragma := range sqlitePragmas {
+		url += sep + "_pragma=" + pragma
+		sep = "&"
+	}
+	return url
+}
+
+func (d *SQLDatabase) Ping(ctx context.Context) error {
+	return d.DB.PingContext(ctx)
+}
+
+func (d *SQLDatabase) Close() {
+	if err := d.DB.Close(); err != nil {
+		slog.Error("failed to close the database", "driver", d.Dialect, logging.Err(err))
+	}
+}
+
+// Rebind rewrites the ? placeholders in query into the dialect's form.
+func (d *SQLDatabase) Rebind(query string) string {
+	if d.Dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (d *SQLDatabase) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return d.DB.ExecContext(ctx, d.Rebind(query), args...)
+}
+
+func (d *SQLDatabase) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.DB.QueryContext(ctx, d.Rebind(query), args...)
+}
+
+func (d *SQLDatabase) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.DB.QueryRowContext(ctx, d.Rebind(query), args...)
+}
+
+// SQLTx is a transaction on a SQLDatabase, taking the same ? placeholders.
+type SQLTx struct {
+	tx *sql.Tx
+	db *SQLDatabase
+}
+
+// InTx runs fn in a transaction that is committed if fn succeeds and
+// rolled back otherwise. With SQLite, fn must not use d itself, which
+// would wait forever for the transaction's connection.
+func (d *SQLDatabase) InTx(ctx context.Context, fn func(tx *SQLTx) error) error {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(&SQLTx{tx: tx, db: d}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (t *SQLTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, t.db.Rebind(query), args...)
+}
+
+func (t *SQLTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, t.db.Rebind(query), args...)
+}
+
+func (t *SQLTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return t.tx.QueryRowContext(ctx, t.db.Rebind(query), args...)
+}
+
+// IsUniqueViolation reports whether err is a SQLite or PostgreSQL
+// unique or primary key constraint violation.
+func IsUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code()
+		return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -1,18 +1,100 @@
 package domain
 
-import "go.mongodb.org/mongo-driver/v2/bson"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// BlogStatus describes where a post is in its lifecycle. It is derived from
+// publishedAt and deletedAt rather than stored.
+type BlogStatus string
+
+const (
+	BlogPublished BlogStatus = "published"
+	BlogScheduled BlogStatus = "scheduled"
+	BlogTrashed   BlogStatus = "trashed"
+)
 
 type Blog struct {
 	ID          bson.ObjectID `bson:"_id,omitempty" json:"id"`
 	Title       string        `bson:"title" json:"title"`
 	Excerpt     string        `bson:"excerpt" json:"excerpt"`
 	Content     string        `bson:"content" json:"content"`
+	ContentHTML string        `bson:"contentHtml" json:"contentHtml"`
+	TOC         []TOCEntry    `bson:"toc" json:"toc"`
 	Author      string        `bson:"author" json:"author"`
 	AuthorImage string        `bson:"authorImage" json:"authorImage"`
-	Date        string        `bson:"date" json:"date"`
 	ReadTime    string        `bson:"readTime" json:"readTime"`
+	WordCount   int           `bson:"wordCount" json:"wordCount"`
 	Category    string        `bson:"category" json:"category"`
 	Tags        []string      `bson:"tags" json:"tags"`
 	Image       string        `bson:"image" json:"image"`
 	Featured    bool          `bson:"featured" json:"featured"`
-}
\ No newline at end of file
+	CreatedAt   time.Time     `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time     `bson:"updatedAt" json:"updatedAt"`
+	PublishedAt *time.Time    `bson:"publishedAt,omitempty" json:"publishedAt,omitempty"`
+	DeletedAt   *time.Time    `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
+
+	// ExcerptAuto and ReadTimeAuto record whether the stored values were
+	// generated from the content rather than supplied by the author.
+	ExcerptAuto  bool `bson:"excerptAuto" json:"-"`
+	ReadTimeAuto bool `bson:"readTimeAuto" json:"-"`
+}
+
+// blogJSON has Blog's fields without its JSON methods.
+type blogJSON Blog
+
+// MarshalJSON adds the legacy "date" field, derived from PublishedAt, so
+// that existing clients keep working.
+func (b Blog) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		blogJSON
+		Date string `json:"date"`
+	}{blogJSON(b), b.DisplayDate()})
+}
+
+// UnmarshalJSON accepts the legacy "date" field as an alternative to
+// publishedAt. Unknown fields are rejected.
+func (b *Blog) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*blogJSON
+		Date string `json:"date"`
+	}{blogJSON: (*blogJSON)(b)}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&aux); err != nil {
+		return err
+	}
+	if b.PublishedAt == nil && aux.Date != "" {
+		t, err := ParseDate(aux.Date)
+		if err != nil {
+			return fmt.Errorf("date: %w", err)
+		}
+		b.PublishedAt = &t
+	}
+	return nil
+}
+
+// DisplayDate formats the publication date, falling back to the creation
+// date for posts that have not been published.
+func (b *Blog) DisplayDate() string {
+	switch {
+	case b.PublishedAt != nil:
+		return b.PublishedAt.Format(DateLayout)
+	case !b.CreatedAt.IsZero():
+		return b.CreatedAt.Format(DateLayout)
+	}
+	return ""
+}
+
+// TOCEntry is a single heading in a post's table of contents. Entries are
+// listed in document order; Level can be used to rebuild the hierarchy.
+type TOCEntry struct {
+	Level int    `bson:"level" json:"level"`
+	ID    string `bson:"id" json:"id"`
+	Text  string `bson:"text" json:"text"`
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type ConsentAction string
+
+const (
+	ConsentSubscribe ConsentAction = "subscribe"
+	ConsentConfirm   ConsentAction = "confirm"
+	ConsentImport    ConsentAction = "import"
+	// ConsentRemove records an admin removing the subscriber. The log is
+	// kept afterwards as evidence of what consent was given and withdrawn.
+	ConsentRemove ConsentAction = "remove"
+)
+
+// ConsentContext describes the request in which a subscriber gave consent.
+type ConsentContext struct {
+	Source      string
+	IP          string
+	UserAgent   string
+	TextVersion string
+}
+
+// ConsentRecord is an entry in the append-only consent log.
+type ConsentRecord struct {
+	ID           bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	SubscriberID bson.ObjectID `bson:"subscriberId" json:"subscriberId"`
+	Action       ConsentAction `bson:"action" json:"action"`
+	Source       string        `bson:"source,omitempty" json:"source,omitempty"`
+	IP           string        `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent    string        `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	TextVersion  string        `bson:"textVersion,omitempty" json:"textVersion,omitempty"`
+	CreatedAt    time.Time     `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateLayout is the format of the legacy "date" field in JSON responses.
+const DateLayout = "January 2, 2006"
+
+// dateLayouts lists the formats authors have historically typed into the
+// free-form date field, most specific first.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	"Monday, January 2, 2006",
+	"January 2, 2006",
+	"January 2 2006",
+	"Jan 2, 2006",
+	"Jan 2 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	"02 Jan 2006",
+	"01/02/2006",
+	time.RFC1123Z,
+	time.RFC1123,
+	"January 2006",
+	"Jan 2006",
+}
+
+// ParseDate parses a date string in any of the supported layouts. Dates
+// without a time zone are interpreted as UTC.
+func ParseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", s)
+}
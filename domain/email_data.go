@@ -9,3 +9,14 @@ type EmailData struct {
 	Tags           []string
 	BlogURL        string
 }
+
+type ConfirmEmailData struct {
+	Email      string
+	ConfirmURL string
+}
+
+type DataAccessEmailData struct {
+	Email     string
+	ExportURL string
+	EraseURL  string
+}
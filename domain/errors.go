@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by repositories when no document matches the
+	// requested ID, independent of the underlying storage driver.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID is returned when a path parameter is not a valid ID.
+	ErrInvalidID = errors.New("invalid id")
+
+	// ErrConflict is returned when a write would violate a uniqueness rule.
+	ErrConflict = errors.New("conflict")
+)
+
+// RateLimitError is returned when a caller has exceeded a rate limit.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after %s", e.RetryAfter)
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type SendKind string
+
+const (
+	SendConfirmation SendKind = "confirmation"
+	SendNewBlog      SendKind = "new_blog"
+	SendDataAccess   SendKind = "data_access"
+)
+
+type SendStatus string
+
+const (
+	SendSent   SendStatus = "sent"
+	SendFailed SendStatus = "failed"
+	// SendSkipped marks an email that was deliberately not sent because
+	// mail is disabled.
+	SendSkipped SendStatus = "skipped"
+)
+
+// SendEvent records a single email delivered (or attempted) to a subscriber.
+type SendEvent struct {
+	ID           bson.ObjectID  `bson:"_id,omitempty" json:"id"`
+	SubscriberID bson.ObjectID  `bson:"subscriberId" json:"subscriberId"`
+	Kind         SendKind       `bson:"kind" json:"kind"`
+	Subject      string         `bson:"subject" json:"subject"`
+	BlogID       *bson.ObjectID `bson:"blogId,omitempty" json:"blogId,omitempty"`
+	Status       SendStatus     `bson:"status" json:"status"`
+	Error        string         `bson:"error,omitempty" json:"error,omitempty"`
+	SentAt       time.Time      `bson:"sentAt" json:"sentAt"`
+}
@@ -1,10 +1,70 @@
 package domain
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
+type SubscriberStatus string
+
+const (
+	SubscriberPending   SubscriberStatus = "pending"
+	SubscriberConfirmed SubscriberStatus = "confirmed"
+)
+
+// Subscriber is a newsletter subscription. EmailKey is the canonical form of
+// Email used to detect duplicates, and ManageToken authenticates the
+// subscriber's own data requests.
 type Subscriber struct {
-	ID        bson.ObjectID `bson:"_id,omitempty" json:"id"`
-	Email     string             `bson:"email" json:"email"`
+	ID           bson.ObjectID    `bson:"_id,omitempty" json:"id"`
+	Email        string           `bson:"email" json:"email"`
+	EmailKey     string           `bson:"emailKey" json:"-"`
+	Status       SubscriberStatus `bson:"status" json:"status"`
+	ConfirmToken string           `bson:"confirmToken,omitempty" json:"-"`
+	ManageToken  string           `bson:"manageToken,omitempty" json:"-"`
+	CreatedAt    time.Time        `bson:"createdAt" json:"createdAt"`
+	ConfirmedAt  *time.Time       `bson:"confirmedAt,omitempty" json:"confirmedAt,omitempty"`
+}
+
+// SubscriberFilter selects subscribers for the admin listing and export.
+// A zero Limit means no limit.
+type SubscriberFilter struct {
+	Search string
+	Status SubscriberStatus
+	Skip   int
+	Limit  int
+}
+
+// SubscriberDetail is a subscriber together with its consent log and most
+// recent sends.
+type SubscriberDetail struct {
+	Subscriber *Subscriber      `json:"subscriber"`
+	Consents   []*ConsentRecord `json:"consents"`
+	Sends      []*SendEvent     `json:"sends"`
+}
+
+// SubscriberExport is a subscriber with its consent log, as written by the
+// admin export.
+type SubscriberExport struct {
+	*Subscriber
+	Consents []*ConsentRecord `json:"consents"`
+}
+
+// ImportReport summarises a bulk subscriber import. When DryRun is set
+// nothing was written and Imported counts the rows that would have been.
+type ImportReport struct {
+	DryRun     bool          `json:"dryRun"`
+	Total      int           `json:"total"`
+	Imported   int           `json:"imported"`
+	Duplicates int           `json:"duplicates"`
+	Existing   int           `json:"existing"`
+	Suppressed int           `json:"suppressed"`
+	Invalid    []ImportIssue `json:"invalid"`
+}
+
+type ImportIssue struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
 }
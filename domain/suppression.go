@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// Suppression prevents an address from being mailed again after its data
+// has been erased. Only a hash of the canonical email is kept.
+type Suppression struct {
+	Hash      string    `bson:"_id" json:"hash"`
+	Reason    string    `bson:"reason" json:"reason"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// SubscriberData is everything held about a subscriber, as returned by a
+// data subject access request.
+type SubscriberData struct {
+	ExportedAt  time.Time        `json:"exportedAt"`
+	Email       string           `json:"email"`
+	EmailKey    string           `json:"canonicalEmail"`
+	Status      SubscriberStatus `json:"status"`
+	CreatedAt   time.Time        `json:"subscribedAt"`
+	ConfirmedAt *time.Time       `json:"confirmedAt,omitempty"`
+	Consents    []*ConsentRecord `json:"consents"`
+	Sends       []*SendEvent     `json:"sends"`
+}
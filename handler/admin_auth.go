@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminAuth requires requests to carry "Authorization: Bearer <token>".
+// When no admin token is configured every request is refused, so admin
+// routes are never accidentally left open.
+func AdminAuth(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeProblem(w, r, http.StatusServiceUnavailable, "Admin access is not configured.", nil)
+				return
+			}
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				writeProblem(w, r, http.StatusUnauthorized, "A valid admin token is required.", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/service"
+	"github.com/tahsin005/codercat-server/validation"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	maxImportBytes  = 10 << 20
+)
+
+type AdminSubscriberHandler struct {
+	service service.SubscriberService
+}
+
+func NewAdminSubscriberHandler(service service.SubscriberService) *AdminSubscriberHandler {
+	return &AdminSubscriberHandler{service: service}
+}
+
+type subscriberPage struct {
+	Items []*domain.Subscriber `json:"items"`
+	Page  int                  `json:"page"`
+	Limit int                  `json:"limit"`
+	Total int64                `json:"total"`
+}
+
+func (h *AdminSubscriberHandler) ListSubscribers(w http.ResponseWriter, r *http.Request) {
+	filter, err := subscriberFilter(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	filter.Skip = (page - 1) * limit
+	filter.Limit = limit
+
+	subscribers, total, err := h.service.ListSubscribers(r.Context(), filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if subscribers == nil {
+		subscribers = []*domain.Subscriber{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriberPage{Items: subscribers, Page: page, Limit: limit, Total: total})
+}
+
+func (h *AdminSubscriberHandler) GetSubscriber(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	detail, err := h.service.GetSubscriber(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if detail.Consents == nil {
+		detail.Consents = []*domain.ConsentRecord{}
+	}
+	if detail.Sends == nil {
+		detail.Sends = []*domain.SendEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+func (h *AdminSubscriberHandler) RemoveSubscriber(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := h.service.RemoveSubscriber(r.Context(), id, consentContext(r, "admin removal", "")); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExportSubscribers streams every subscriber matching the search and status
+// filters as CSV (the default) or JSON.
+func (h *AdminSubscriberHandler) ExportSubscribers(w http.ResponseWriter, r *http.Request) {
+	filter, err := subscriberFilter(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		writeError(w, r, validation.Errors{{Field: "format", Message: "must be csv or json"}})
+		return
+	}
+
+	exports, err := h.service.ExportSubscribers(r.Context(), filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	filename := "subscribers-" + time.Now().UTC().Format("20060102") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(exports)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"id", "email", "status", "createdAt", "confirmedAt",
+		"consentVersion", "subscribeSource", "subscribeIp", "subscribeUserAgent", "confirmIp",
+	})
+	for _, e := range exports {
+		confirmedAt := ""
+		if e.ConfirmedAt != nil {
+			confirmedAt = e.ConfirmedAt.Format(time.RFC3339)
+		}
+		subscribe := latestConsent(e.Consents, domain.ConsentSubscribe, domain.ConsentImport)
+		confirm := latestConsent(e.Consents, domain.ConsentConfirm)
+		cw.Write([]string{
+			e.ID.Hex(), e.Email, string(e.Status), e.CreatedAt.Format(time.RFC3339), confirmedAt,
+			subscribe.TextVersion, subscribe.Source, subscribe.IP, subscribe.UserAgent, confirm.IP,
+		})
+	}
+	cw.Flush()
+}
+
+// latestConsent returns the most recent record with one of the given
+// actions, or an empty record if there is none.
+func latestConsent(records []*domain.ConsentRecord, actions ...domain.ConsentAction) domain.ConsentRecord {
+	for i := len(records) - 1; i >= 0; i-- {
+		for _, a := range actions {
+			if records[i].Action == a {
+				return *records[i]
+			}
+		}
+	}
+	return domain.ConsentRecord{}
+}
+
+// ImportSubscribers accepts a CSV file either as the raw request body or as
+// the "file" field of a multipart form. Pass dryRun=true to validate without
+// writing anything.
+func (h *AdminSubscriberHandler) ImportSubscribers(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, err)
+			return
+		}
+		if err != nil {
+			writeError(w, r, validation.Errors{{Field: "file", Message: "is required"}})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	report, err := h.service.ImportSubscribers(r.Context(), body, dryRun, consentContext(r, "admin import", ""))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	status := http.StatusOK
+	if !dryRun && report.Imported > 0 {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+func subscriberFilter(r *http.Request) (domain.SubscriberFilter, error) {
+	q := r.URL.Query()
+	filter := domain.SubscriberFilter{
+		Search: q.Get("search"),
+		Status: domain.SubscriberStatus(q.Get("status")),
+	}
+	switch filter.Status {
+	case "", domain.SubscriberPending, domain.SubscriberConfirmed:
+		return filter, nil
+	}
+	return filter, validation.Errors{{Field: "status", Message: "must be pending or confirmed"}}
+}
+
+func (h *AdminSubscriberHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/subscribers", h.ListSubscribers).Methods("GET")
+	router.HandleFunc("/subscribers/export", h.ExportSubscribers).Methods("GET")
+	router.HandleFunc("/subscribers/import", h.ImportSubscribers).Methods("POST")
+	router.HandleFunc("/subscribers/{id}", h.GetSubscriber).Methods("GET")
+	router.HandleFunc("/subscribers/{id}", h.RemoveSubscriber).Methods("DELETE")
+}
@@ -0,0 +1,209 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tahsin005/codercat-server/domain"
+)
+
+func TestAdminAuth(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(t, "GET", "/admin/subscribers", "")
+	assertProblem(t, rec, http.StatusUnauthorized)
+	if rec.Header().Get("WWW-Authenticate") == "" {
+		t.Error("401 without WWW-Authenticate")
+	}
+	assertProblem(t, s.do(t, "GET", "/admin/subscribers", "", "Authorization", "Bearer wrong"), http.StatusUnauthorized)
+	assertStatus(t, s.admin(t, "GET", "/admin/subscribers", ""), http.StatusOK)
+}
+
+// importSubscribers imports a CSV of email,status rows and fails the test
+// unless every row was imported.
+func importSubscribers(t *testing.T, s *testServer, csv string) {
+	t.Helper()
+	rec := s.admin(t, "POST", "/admin/subscribers/import", csv)
+	assertStatus(t, rec, http.StatusCreated)
+	var report domain.ImportReport
+	decode(t, rec, &report)
+	if report.Imported != report.Total {
+		t.Fatalf("import report = %+v", report)
+	}
+}
+
+func TestAdminListSubscribers(t *testing.T) {
+	s := newTestServer(t)
+	importSubscribers(t, s, "email,status\nada@example.com,confirmed\nbob@example.com,pending\ncy@test.org,confirmed\n")
+
+	emails := func(subs []domain.Subscriber) []string {
+		var out []string
+		for _, sub := range subs {
+			out = append(out, sub.Email)
+		}
+		slices.Sort(out)
+		return out
+	}
+	tests := []struct {
+		query string
+		want  []string
+		total int64
+	}{
+		{"", []string{"ada@example.com", "bob@example.com", "cy@test.org"}, 3},
+		{"?status=confirmed", []string{"ada@example.com", "cy@test.org"}, 2},
+		{"?search=EXAMPLE", []string{"ada@example.com", "bob@example.com"}, 2},
+		{"?limit=2&page=2", []string{"ada@example.com"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := s.admin(t, "GET", "/admin/subscribers"+tt.query, "")
+			assertStatus(t, rec, http.StatusOK)
+			var page struct {
+				Items []domain.Subscriber
+				Total int64
+			}
+			decode(t, rec, &page)
+			if got := emails(page.Items); !slices.Equal(got, tt.want) || page.Total != tt.total {
+				t.Errorf("items = %v (total %d), want %v (total %d)", got, page.Total, tt.want, tt.total)
+			}
+		})
+	}
+
+	assertProblem(t, s.admin(t, "GET", "/admin/subscribers?status=gone", ""), http.StatusUnprocessableEntity)
+}
+
+func TestAdminExportSubscribers(t *testing.T) {
+	s := newTestServer(t)
+	importSubscribers(t, s, "email,status\nada@example.com,confirmed\n")
+
+	rec := s.admin(t, "GET", "/admin/subscribers/export", "")
+	assertStatus(t, rec, http.StatusOK)
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") || !strings.Contains(rec.Header().Get("Content-Disposition"), ".csv") {
+		t.Errorf("headers = %v", rec.Header())
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1][1] != "ada@example.com" || rows[1][6] != "admin import" {
+		t.Errorf("CSV rows = %v", rows)
+	}
+
+	rec = s.admin(t, "GET", "/admin/subscribers/export?format=json", "")
+	assertStatus(t, rec, http.StatusOK)
+	var exports []struct {
+		Email    string
+		Consents []domain.ConsentRecord
+	}
+	decode(t, rec, &exports)
+	if len(exports) != 1 || exports[0].Email != "ada@example.com" || len(exports[0].Consents) != 1 {
+		t.Errorf("JSON export = %+v", exports)
+	}
+
+	assertProblem(t, s.admin(t, "GET", "/admin/subscribers/export?format=xml", ""), http.StatusUnprocessableEntity)
+}
+
+func TestAdminImportSubscribers(t *testing.T) {
+	s := newTestServer(t)
+	csvBody := "email,status\nada@example.com,confirmed\nADA@example.com,\nnot-an-email,\nbob@example.com,\n"
+
+	rec := s.admin(t, "POST", "/admin/subscribers/import?dryRun=true", csvBody)
+	assertStatus(t, rec, http.StatusOK)
+	var report domain.ImportReport
+	decode(t, rec, &report)
+	if !report.DryRun || report.Total != 4 || report.Imported != 2 || report.Duplicates != 1 || len(report.Invalid) != 1 || report.Invalid[0].Line != 4 {
+		t.Errorf("dry run report = %+v", report)
+	}
+	if subs, _ := s.subscribers.GetAll(context.Background()); len(subs) != 0 {
+		t.Fatalf("dry run created %d subscribers", len(subs))
+	}
+	s.mail.none(t)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, _ := mw.CreateFormFile("file", "subscribers.csv")
+	part.Write([]byte(csvBody))
+	mw.Close()
+	rec = s.admin(t, "POST", "/admin/subscribers/import", form.String(), "Content-Type", mw.FormDataContentType())
+	assertStatus(t, rec, http.StatusCreated)
+	decode(t, rec, &report)
+	if report.DryRun || report.Imported != 2 {
+		t.Errorf("import report = %+v", report)
+	}
+	bob, err := s.subscribers.FindByEmailKey(context.Background(), "bob@example.com")
+	if err != nil || bob.Status != domain.SubscriberPending {
+		t.Errorf("bob = %+v, %v", bob, err)
+	}
+	// Rows without an explicit status still have to confirm, so they are
+	// asked to.
+	if email := s.mail.next(t); !slices.Equal(email.to, []string{"bob@example.com"}) || !strings.Contains(email.body, bob.ConfirmToken) {
+		t.Errorf("confirmation email = %+v", email)
+	}
+	s.mail.none(t)
+
+	rec = s.admin(t, "POST", "/admin/subscribers/import", csvBody)
+	assertStatus(t, rec, http.StatusOK)
+	decode(t, rec, &report)
+	if report.Imported != 0 || report.Existing != 2 {
+		t.Errorf("re-import report = %+v", report)
+	}
+
+	rec = s.admin(t, "POST", "/admin/subscribers/import", "email\ncy@example.com\n")
+	assertStatus(t, rec, http.StatusCreated)
+	if cy, err := s.subscribers.FindByEmailKey(context.Background(), "cy@example.com"); err != nil || cy.Status != domain.SubscriberPending {
+		t.Errorf("cy = %+v, %v", cy, err)
+	}
+	s.mail.next(t)
+
+	assertProblem(t, s.admin(t, "POST", "/admin/subscribers/import", "name\nada\n"), http.StatusUnprocessableEntity)
+	assertProblem(t, s.admin(t, "POST", "/admin/subscribers/import", "x", "Content-Type", "multipart/form-data; boundary=nothing"), http.StatusUnprocessableEntity)
+
+	// The import route's limit replaces the 1MB default.
+	large := "email\n" + strings.Repeat("someone@example.com\n", 100_000)
+	assertStatus(t, s.admin(t, "POST", "/admin/subscribers/import?dryRun=true", large), http.StatusOK)
+	tooLarge := "email\n" + strings.Repeat("someone@example.com\n", 600_000)
+	p := assertProblem(t, s.admin(t, "POST", "/admin/subscribers/import", tooLarge), http.StatusRequestEntityTooLarge)
+	if !strings.Contains(p.Detail, "10485760 bytes") {
+		t.Errorf("problem detail = %q", p.Detail)
+	}
+	form.Reset()
+	mw = multipart.NewWriter(&form)
+	part, _ = mw.CreateFormFile("file", "subscribers.csv")
+	part.Write([]byte(tooLarge))
+	mw.Close()
+	assertProblem(t, s.admin(t, "POST", "/admin/subscribers/import", form.String(), "Content-Type", mw.FormDataContentType()), http.StatusRequestEntityTooLarge)
+}
+
+func TestAdminGetAndRemoveSubscriber(t *testing.T) {
+	s := newTestServer(t)
+	importSubscribers(t, s, "email,status\nada@example.com,confirmed\n")
+	sub, err := s.subscribers.FindByEmailKey(context.Background(), "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/admin/subscribers/" + sub.ID.Hex()
+
+	rec := s.admin(t, "GET", path, "")
+	assertStatus(t, rec, http.StatusOK)
+	var detail domain.SubscriberDetail
+	decode(t, rec, &detail)
+	if detail.Subscriber == nil || detail.Subscriber.Email != "ada@example.com" || len(detail.Consents) != 1 || detail.Sends == nil {
+		t.Errorf("detail = %+v", detail)
+	}
+
+	assertStatus(t, s.admin(t, "DELETE", path, ""), http.StatusNoContent)
+	assertProblem(t, s.admin(t, "GET", path, ""), http.StatusNotFound)
+	assertProblem(t, s.admin(t, "DELETE", path, ""), http.StatusNotFound)
+	assertProblem(t, s.admin(t, "GET", "/admin/subscribers/nope", ""), http.StatusBadRequest)
+	// The consent log outlives the subscriber and records the removal.
+	consents, _ := s.consents.FindBySubscriber(context.Background(), sub.ID)
+	if len(consents) != 2 || consents[1].Action != domain.ConsentRemove || consents[1].Source != "admin removal" {
+		t.Errorf("consent log after removal = %+v", consents)
+	}
+}
@@ -1,31 +1,35 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/cache"
 	"github.com/tahsin005/codercat-server/domain"
 	"github.com/tahsin005/codercat-server/service"
 )
 
 type BlogHandler struct {
 	service service.BlogService
+	// cachePolicies set how long clients may reuse the public responses,
+	// by route.
+	cachePolicies map[string]cache.Policy
 }
 
-func NewBlogHandler(service service.BlogService) *BlogHandler {
-	return &BlogHandler{service: service}
+func NewBlogHandler(service service.BlogService, cachePolicies map[string]cache.Policy) *BlogHandler {
+	return &BlogHandler{service: service, cachePolicies: cachePolicies}
 }
 
 func (h *BlogHandler) CreateBlog(w http.ResponseWriter, r *http.Request) {
 	var blog domain.Blog
-	if err := json.NewDecoder(r.Body).Decode(&blog); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &blog) {
 		return
 	}
 	if err := h.service.CreateBlog(r.Context(), &blog); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -37,22 +41,20 @@ func (h *BlogHandler) GetBlog(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 	blog, err := h.service.GetBlogByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(blog)
+	writeCacheableJSON(w, r, blog, cachePolicy(h.cachePolicies, r), blog.UpdatedAt)
 }
 
 func (h *BlogHandler) UpdateBlog(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 	var blog domain.Blog
-	if err := json.NewDecoder(r.Body).Decode(&blog); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &blog) {
 		return
 	}
 	if err := h.service.UpdateBlog(r.Context(), id, &blog); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -62,30 +64,59 @@ func (h *BlogHandler) UpdateBlog(w http.ResponseWriter, r *http.Request) {
 func (h *BlogHandler) DeleteBlog(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 	if err := h.service.DeleteBlog(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *BlogHandler) GetAllBlogs(w http.ResponseWriter, r *http.Request) {
-	blogs, err := h.service.GetAllBlogs(r.Context())
+func (h *BlogHandler) GetTrashedBlogs(w http.ResponseWriter, r *http.Request) {
+	blogs, err := h.service.GetTrashedBlogs(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	if blogs == nil {
+		blogs = []*domain.Blog{}
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(blogs)
 }
 
-func (h *BlogHandler) GetFeaturedBlogs(w http.ResponseWriter, r *http.Request) {
-	blogs, err := h.service.GetFeaturedBlogs(r.Context())
+func (h *BlogHandler) RestoreBlog(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := h.service.RestoreBlog(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	blog, err := h.service.GetBlogByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(blogs)
+	json.NewEncoder(w).Encode(blog)
+}
+
+func (h *BlogHandler) PurgeBlog(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := h.service.PurgeBlog(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *BlogHandler) GetAllBlogs(w http.ResponseWriter, r *http.Request) {
+	h.writeList(w, r, func(ctx context.Context) (any, error) {
+		return h.service.GetAllBlogs(ctx)
+	})
+}
+
+func (h *BlogHandler) GetFeaturedBlogs(w http.ResponseWriter, r *http.Request) {
+	h.writeList(w, r, func(ctx context.Context) (any, error) {
+		return h.service.GetFeaturedBlogs(ctx)
+	})
 }
 
 func (h *BlogHandler) GetRecentBlogs(w http.ResponseWriter, r *http.Request) {
@@ -94,39 +125,27 @@ func (h *BlogHandler) GetRecentBlogs(w http.ResponseWriter, r *http.Request) {
 	if limit == 0 {
 		limit = 3
 	}
-	blogs, err := h.service.GetRecentBlogs(r.Context(), limit)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(blogs)
+	h.writeList(w, r, func(ctx context.Context) (any, error) {
+		return h.service.GetRecentBlogs(ctx, limit)
+	})
 }
 
 func (h *BlogHandler) GetBlogsByCategory(w http.ResponseWriter, r *http.Request) {
 	category := mux.Vars(r)["category"]
-	blogs, err := h.service.GetBlogsByCategory(r.Context(), category)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(blogs)
+	h.writeList(w, r, func(ctx context.Context) (any, error) {
+		return h.service.GetBlogsByCategory(ctx, category)
+	})
 }
 
 func (h *BlogHandler) SearchBlogs(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("query")
-	blogs, err := h.service.SearchBlogs(r.Context(), query)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	if len(blogs) == 0 {
-		json.NewEncoder(w).Encode([]string{})
-		return
-	}
-	json.NewEncoder(w).Encode(blogs)
+	h.writeList(w, r, func(ctx context.Context) (any, error) {
+		blogs, err := h.service.SearchBlogs(ctx, query)
+		if len(blogs) == 0 {
+			return []string{}, err
+		}
+		return blogs, err
+	})
 }
 
 func (h *BlogHandler) GetRelatedBlogs(w http.ResponseWriter, r *http.Request) {
@@ -136,23 +155,15 @@ func (h *BlogHandler) GetRelatedBlogs(w http.ResponseWriter, r *http.Request) {
 	if limit == 0 {
 		limit = 2
 	}
-	blogs, err := h.service.GetRelatedBlogs(r.Context(), id, limit)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(blogs)
+	h.writeList(w, r, func(ctx context.Context) (any, error) {
+		return h.service.GetRelatedBlogs(ctx, id, limit)
+	})
 }
 
 func (h *BlogHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
-	categories, err := h.service.GetCategories(r.Context())
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(categories)
+	h.writeList(w, r, func(ctx context.Context) (any, error) {
+		return h.service.GetCategories(ctx)
+	})
 }
 
 func (h *BlogHandler) GetPopularCategories(w http.ResponseWriter, r *http.Request) {
@@ -161,14 +172,35 @@ func (h *BlogHandler) GetPopularCategories(w http.ResponseWriter, r *http.Reques
 	if limit == 0 {
 		limit = 5 // default to top 5 categories
 	}
+	h.writeList(w, r, func(ctx context.Context) (any, error) {
+		return h.service.GetPopularCategories(ctx, limit)
+	})
+}
 
-	categories, err := h.service.GetPopularCategories(r.Context(), limit)
+// writeList serves a listing of posts or categories. Any post change can
+// alter a listing, so its Last-Modified is that of the whole blog, which
+// also lets a request holding the latest version skip loading it.
+func (h *BlogHandler) writeList(w http.ResponseWriter, r *http.Request, load func(ctx context.Context) (any, error)) {
+	ctx := r.Context()
+	policy := cachePolicy(h.cachePolicies, r)
+	// Read before the listing, so that a change in between leaves the
+	// response looking older rather than newer than it is.
+	lastModified, err := h.service.GetLastModified(ctx)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(categories)
+	lastModified = settled(lastModified)
+	if notModified(r, "", lastModified) {
+		writeNotModified(w, policy, lastModified)
+		return
+	}
+	v, err := load(ctx)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeCacheableJSON(w, r, v, policy, lastModified)
 }
 
 func (h *BlogHandler) Ping(w http.ResponseWriter, r *http.Request) {
@@ -193,3 +225,12 @@ func (h *BlogHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/blogs", h.GetAllBlogs).Methods("GET")
 	router.HandleFunc("/blogs/category/{category}", h.GetBlogsByCategory).Methods("GET")
 }
+
+// RegisterAdminRoutes registers the trash, which lists withdrawn posts and
+// purges them for good, on a router that is already protected by
+// AdminAuth.
+func (h *BlogHandler) RegisterAdminRoutes(router *mux.Router) {
+	router.HandleFunc("/blogs/trash", h.GetTrashedBlogs).Methods("GET")
+	router.HandleFunc("/blogs/trash/{id}/restore", h.RestoreBlog).Methods("POST")
+	router.HandleFunc("/blogs/trash/{id}", h.PurgeBlog).Methods("DELETE")
+}
@@ -0,0 +1,271 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+)
+
+func TestBlogLifecycle(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(t, "POST", "/blogs", `{"title":"Hello","author":"Cat","category":"Go","content":"# Intro\n\nSome *markdown*."}`)
+	assertStatus(t, rec, http.StatusCreated)
+	var created domain.Blog
+	decode(t, rec, &created)
+	if created.ID.IsZero() || !strings.Contains(created.ContentHTML, "<em>markdown</em>") || len(created.TOC) != 1 {
+		t.Fatalf("created post = %+v", created)
+	}
+	id := created.ID.Hex()
+
+	rec = s.do(t, "GET", "/blogs/"+id, "")
+	assertStatus(t, rec, http.StatusOK)
+	var got domain.Blog
+	decode(t, rec, &got)
+	if got.Title != "Hello" {
+		t.Errorf("GET title = %q", got.Title)
+	}
+
+	rec = s.do(t, "PUT", "/blogs/"+id, `{"title":"Hello again","author":"Cat","category":"Go","content":"Updated."}`)
+	assertStatus(t, rec, http.StatusOK)
+	stored, err := s.blogs.FindByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Title != "Hello again" || !stored.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("after PUT: title %q, createdAt %v (was %v)", stored.Title, stored.CreatedAt, created.CreatedAt)
+	}
+
+	assertStatus(t, s.do(t, "DELETE", "/blogs/"+id, ""), http.StatusNoContent)
+	assertProblem(t, s.do(t, "GET", "/blogs/"+id, ""), http.StatusNotFound)
+
+	assertProblem(t, s.do(t, "GET", "/admin/blogs/trash", ""), http.StatusUnauthorized)
+	assertProblem(t, s.do(t, "DELETE", "/admin/blogs/trash/"+id, ""), http.StatusUnauthorized)
+
+	rec = s.admin(t, "GET", "/admin/blogs/trash", "")
+	assertStatus(t, rec, http.StatusOK)
+	var trashed []domain.Blog
+	decode(t, rec, &trashed)
+	if len(trashed) != 1 || trashed[0].ID != created.ID {
+		t.Errorf("trash = %+v", trashed)
+	}
+
+	rec = s.admin(t, "POST", "/admin/blogs/trash/"+id+"/restore", "")
+	assertStatus(t, rec, http.StatusOK)
+	decode(t, rec, &got)
+	if got.ID != created.ID {
+		t.Errorf("restore returned %+v", got)
+	}
+
+	assertProblem(t, s.admin(t, "DELETE", "/admin/blogs/trash/"+id, ""), http.StatusNotFound)
+	assertStatus(t, s.do(t, "DELETE", "/blogs/"+id, ""), http.StatusNoContent)
+	assertStatus(t, s.admin(t, "DELETE", "/admin/blogs/trash/"+id, ""), http.StatusNoContent)
+	assertProblem(t, s.admin(t, "POST", "/admin/blogs/trash/"+id+"/restore", ""), http.StatusNotFound)
+}
+
+func TestBlogErrors(t *testing.T) {
+	s := newTestServer(t)
+
+	tooLarge := `{"title":"x","author":"Cat","category":"Go","content":"` + strings.Repeat("x", 1<<20) + `"}`
+	p := assertProblem(t, s.do(t, "POST", "/blogs", tooLarge), http.StatusRequestEntityTooLarge)
+	if !strings.Contains(p.Detail, "1048576 bytes") {
+		t.Errorf("problem detail = %q", p.Detail)
+	}
+
+	p = assertProblem(t, s.do(t, "POST", "/blogs", `{"title":"","author":"Cat","category":"Go","content":"x"}`), http.StatusUnprocessableEntity)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "title" {
+		t.Errorf("field errors = %+v", p.Errors)
+	}
+	assertProblem(t, s.do(t, "POST", "/blogs", `{"title":"x","unknown":1}`), http.StatusBadRequest)
+	assertProblem(t, s.do(t, "POST", "/blogs", `{"title":`), http.StatusBadRequest)
+	assertProblem(t, s.do(t, "GET", "/blogs/not-an-id", ""), http.StatusBadRequest)
+	assertProblem(t, s.do(t, "PUT", "/blogs/000000000000000000000000", `{"title":"x","author":"Cat","category":"Go","content":"x"}`), http.StatusNotFound)
+	assertProblem(t, s.do(t, "DELETE", "/blogs/000000000000000000000000", ""), http.StatusNotFound)
+	assertProblem(t, s.do(t, "GET", "/blogs/related/000000000000000000000000", ""), http.StatusNotFound)
+}
+
+func TestBlogListings(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	day := func(d int) *time.Time {
+		t := time.Date(2024, time.February, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	posts := []*domain.Blog{
+		{Title: "Goroutines", Category: "Go", Tags: []string{"concurrency"}, Content: "Channels everywhere.", PublishedAt: day(1)},
+		{Title: "Generics", Category: "Go", Content: "Type parameters.", Featured: true, PublishedAt: day(3)},
+		{Title: "Ownership", Category: "Rust", Tags: []string{"concurrency"}, Content: "Borrowing.", PublishedAt: day(2)},
+		{Title: "Compost", Category: "Garden", Content: "Leaves.", PublishedAt: day(4)},
+	}
+	for _, p := range posts {
+		if err := s.blogs.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	goroutines := posts[0].ID.Hex()
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/blogs", []string{"Goroutines", "Generics", "Ownership", "Compost"}},
+		{"/blogs/featured", []string{"Generics"}},
+		{"/blogs/recent", []string{"Compost", "Generics", "Ownership"}},
+		{"/blogs/recent?limit=1", []string{"Compost"}},
+		{"/blogs/category/Go", []string{"Goroutines", "Generics"}},
+		{"/blogs/category/All", []string{"Goroutines", "Generics", "Ownership", "Compost"}},
+		{"/blogs/search?query=borrowing", []string{"Ownership"}},
+		{"/blogs/search?query=nothing", []string{}},
+		{"/blogs/related/" + goroutines, []string{"Generics", "Ownership"}},
+		{"/blogs/related/" + goroutines + "?limit=1", []string{"Generics"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := s.do(t, "GET", tt.path, "")
+			assertStatus(t, rec, http.StatusOK)
+			var blogs []domain.Blog
+			decode(t, rec, &blogs)
+			titles := []string{}
+			for _, b := range blogs {
+				titles = append(titles, b.Title)
+			}
+			if !slices.Equal(titles, tt.want) {
+				t.Errorf("titles = %v, want %v", titles, tt.want)
+			}
+		})
+	}
+
+	for path, want := range map[string][]string{
+		"/categories":                 {"All", "Garden", "Go", "Rust"},
+		"/categories/popular":         {"Go", "Garden", "Rust"},
+		"/categories/popular?limit=1": {"Go"},
+	} {
+		t.Run(path, func(t *testing.T) {
+			rec := s.do(t, "GET", path, "")
+			assertStatus(t, rec, http.StatusOK)
+			var categories []string
+			decode(t, rec, &categories)
+			if !slices.Equal(categories, want) {
+				t.Errorf("categories = %v, want %v", categories, want)
+			}
+		})
+	}
+
+	t.Run("/ping", func(t *testing.T) {
+		rec := s.do(t, "GET", "/ping", "")
+		assertStatus(t, rec, http.StatusOK)
+		if rec.Body.String() != "pong" {
+			t.Errorf("body = %q", rec.Body)
+		}
+	})
+}
+
+func TestBlogListingCache(t *testing.T) {
+	s := newTestServer(t)
+	featured := func(rec *httptest.ResponseRecorder) []string {
+		t.Helper()
+		var blogs []domain.Blog
+		decode(t, rec, &blogs)
+		titles := []string{}
+		for _, b := range blogs {
+			titles = append(titles, b.Title)
+		}
+		return titles
+	}
+
+	rec := s.do(t, "GET", "/blogs/featured", "")
+	assertStatus(t, rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	if etag == "" || rec.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+
+	rec = s.do(t, "GET", "/blogs/featured", "", "If-None-Match", `"other", W/`+etag)
+	assertStatus(t, rec, http.StatusNotModified)
+	if rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+		t.Errorf("304 body %q, ETag %q", rec.Body, rec.Header().Get("ETag"))
+	}
+
+	// A write that bypasses the service is not seen until the entry
+	// expires.
+	now := time.Now()
+	if err := s.blogs.Create(context.Background(), &domain.Blog{Title: "Direct", Featured: true, PublishedAt: &now}); err != nil {
+		t.Fatal(err)
+	}
+	rec = s.do(t, "GET", "/blogs/featured", "")
+	if got := featured(rec); len(got) != 0 || rec.Header().Get("ETag") != etag {
+		t.Errorf("cached featured = %v, ETag %q", got, rec.Header().Get("ETag"))
+	}
+
+	// One through the API clears the cache.
+	assertStatus(t, s.do(t, "POST", "/blogs", `{"title":"Via API","author":"Cat","category":"Go","content":"x","featured":true}`), http.StatusCreated)
+	rec = s.do(t, "GET", "/blogs/featured", "", "If-None-Match", etag)
+	assertStatus(t, rec, http.StatusOK)
+	if got := featured(rec); len(got) != 2 || rec.Header().Get("ETag") == etag {
+		t.Errorf("featured after create = %v, ETag %q", got, rec.Header().Get("ETag"))
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	s := newTestServer(t)
+	rec := s.do(t, "POST", "/blogs", `{"title":"Hello","author":"Cat","category":"Go","content":"x"}`)
+	assertStatus(t, rec, http.StatusCreated)
+	var created domain.Blog
+	decode(t, rec, &created)
+	path := "/blogs/" + created.ID.Hex()
+
+	// Dates in the current second are withheld, since a later change in
+	// the same second would share them.
+	rec = s.do(t, "GET", path, "")
+	assertStatus(t, rec, http.StatusOK)
+	if rec.Header().Get("Last-Modified") != "" || rec.Header().Get("ETag") == "" {
+		t.Errorf("fresh post headers = %v", rec.Header())
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=300, stale-while-revalidate=3600" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+
+	rec = s.do(t, "GET", path, "")
+	etag, lastModified := rec.Header().Get("ETag"), rec.Header().Get("Last-Modified")
+	if lastModified != created.UpdatedAt.UTC().Format(http.TimeFormat) {
+		t.Fatalf("Last-Modified = %q, post updated %v", lastModified, created.UpdatedAt)
+	}
+	hourBefore := created.UpdatedAt.Add(-time.Hour).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name   string
+		header []string
+		want   int
+	}{
+		{"etag", []string{"If-None-Match", etag}, http.StatusNotModified},
+		{"other etag", []string{"If-None-Match", `"other"`}, http.StatusOK},
+		{"date", []string{"If-Modified-Since", lastModified}, http.StatusNotModified},
+		{"earlier date", []string{"If-Modified-Since", hourBefore}, http.StatusOK},
+		{"etag wins", []string{"If-None-Match", `"other"`, "If-Modified-Since", lastModified}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertStatus(t, s.do(t, "GET", path, "", tt.header...), tt.want)
+		})
+	}
+
+	// Listings revalidate by date without being loaded, and a removal
+	// moves their date on.
+	rec = s.do(t, "GET", "/blogs", "", "If-Modified-Since", lastModified)
+	assertStatus(t, rec, http.StatusNotModified)
+	if rec.Header().Get("Last-Modified") != lastModified || rec.Header().Get("ETag") != "" {
+		t.Errorf("304 listing headers = %v", rec.Header())
+	}
+	assertStatus(t, s.do(t, "DELETE", path, ""), http.StatusNoContent)
+	rec = s.do(t, "GET", "/blogs", "", "If-Modified-Since", lastModified)
+	assertStatus(t, rec, http.StatusOK)
+	if rec.Body.String() != "null\n" {
+		t.Errorf("listing after delete = %q", rec.Body)
+	}
+}
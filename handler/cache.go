@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/cache"
+)
+
+// cachePolicy returns the policy for the request's route. policies is
+// keyed like body limits: method and path template, path template, or
+// "*".
+func cachePolicy(policies map[string]cache.Policy, r *http.Request) cache.Policy {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			if p, ok := policies[r.Method+" "+tmpl]; ok {
+				return p
+			}
+			if p, ok := policies[tmpl]; ok {
+				return p
+			}
+		}
+	}
+	return policies["*"]
+}
+
+// writeCacheableJSON writes v as JSON under policy, with a strong ETag of
+// the body and, unless it is zero, lastModified. A request that already
+// holds this version gets 304 Not Modified instead.
+func writeCacheableJSON(w http.ResponseWriter, r *http.Request, v any, policy cache.Policy, lastModified time.Time) {
+	lastModified = settled(lastModified)
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	setValidators(w.Header(), policy, etag, lastModified)
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body.Bytes())
+}
+
+// writeNotModified answers a conditional request that notModified
+// accepted before the response body was known.
+func writeNotModified(w http.ResponseWriter, policy cache.Policy, lastModified time.Time) {
+	setValidators(w.Header(), policy, "", lastModified)
+	w.WriteHeader(http.StatusNotModified)
+}
+
+func setValidators(h http.Header, policy cache.Policy, etag string, lastModified time.Time) {
+	h.Set("Cache-Control", policy.Header())
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// notModified reports whether the request's validators show that the
+// client already has the version with etag and lastModified. As RFC 9110
+// requires, If-Modified-Since is only considered without If-None-Match.
+// An empty etag is not yet known, so only the date can be checked.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etag != "" && etagMatches(header, etag)
+	}
+	header := r.Header.Get("If-Modified-Since")
+	if header == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(header)
+	return err == nil && !lastModified.Truncate(time.Second).After(since)
+}
+
+// settled returns t if it lies in an earlier second than now, and the
+// zero time otherwise. HTTP dates have whole seconds, so a later change in
+// the current second would share the date and go unnoticed.
+func settled(t time.Time) time.Time {
+	if !t.Truncate(time.Second).Before(time.Now().Truncate(time.Second)) {
+		return time.Time{}
+	}
+	return t
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using
+// the weak comparison RFC 9110 requires for it.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/middleware"
+)
+
+const (
+	maxConsentSourceLength = 2048
+	maxUserAgentLength     = 512
+)
+
+// consentContext captures where a consent-bearing request came from. The
+// source page falls back to the Referer header when the client omits it.
+func consentContext(r *http.Request, source, textVersion string) domain.ConsentContext {
+	if source == "" {
+		source = r.Referer()
+	}
+	return domain.ConsentContext{
+		Source:      truncate(source, maxConsentSourceLength),
+		IP:          middleware.ClientHost(r),
+		UserAgent:   truncate(r.UserAgent(), maxUserAgentLength),
+		TextVersion: truncate(textVersion, 64),
+	}
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
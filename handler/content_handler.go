@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/service"
+)
+
+type ContentHandler struct {
+	service service.MarkdownService
+}
+
+func NewContentHandler(service service.MarkdownService) *ContentHandler {
+	return &ContentHandler{service: service}
+}
+
+// GetHighlightCSS serves the stylesheet for the syntax-highlighting classes
+// emitted in rendered post content.
+func (h *ContentHandler) GetHighlightCSS(w http.ResponseWriter, r *http.Request) {
+	css, err := h.service.HighlightCSS()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Write([]byte(css))
+}
+
+func (h *ContentHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/content/highlight.css", h.GetHighlightCSS).Methods("GET")
+}
@@ -0,0 +1,20 @@
+package handler_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGetHighlightCSS(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(t, "GET", "/content/highlight.css", "")
+	assertStatus(t, rec, http.StatusOK)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), ".chroma") {
+		t.Errorf("stylesheet has no highlight classes: %.100s", rec.Body)
+	}
+}
@@ -0,0 +1,200 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/cache"
+	"github.com/tahsin005/codercat-server/handler"
+	"github.com/tahsin005/codercat-server/middleware"
+	"github.com/tahsin005/codercat-server/ratelimit"
+	"github.com/tahsin005/codercat-server/repository"
+	"github.com/tahsin005/codercat-server/service"
+)
+
+const (
+	testBaseURL    = "https://codercat.test"
+	testAdminToken = "admin-secret"
+)
+
+// testSuppressionKey keys the hashes of erased addresses.
+var testSuppressionKey = []byte("suppression-secret")
+
+// testServer is the full router, wired to in-memory repositories as main
+// wires it to Mongo, with outgoing email captured instead of sent.
+type testServer struct {
+	router       http.Handler
+	blogs        repository.BlogRepository
+	subscribers  repository.SubscriberRepository
+	sends        repository.SendRepository
+	suppressions repository.SuppressionRepository
+	consents     repository.ConsentRepository
+	mail         *captureSender
+	mongoHealthy bool
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	s := &testServer{
+		blogs:        repository.NewMemoryBlogRepository(),
+		subscribers:  repository.NewMemorySubscriberRepository(),
+		sends:        repository.NewMemorySendRepository(),
+		suppressions: repository.NewMemorySuppressionRepository(),
+		consents:     repository.NewMemoryConsentRepository(),
+		mail:         &captureSender{sent: make(chan sentEmail, 100)},
+		mongoHealthy: true,
+	}
+
+	templateService := service.NewTemplateService("../templates")
+	markdownService := service.NewMarkdownService()
+	mailService := service.NewMailService(s.sends, s.mail)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		mailService.Close(ctx)
+	})
+	subscriberService := service.NewSubscriberService(s.subscribers, s.sends, s.suppressions, s.consents, mailService, templateService, testBaseURL, []string{"2", "1"}, false, testSuppressionKey)
+	blogService := service.NewBlogService(s.blogs, subscriberService, mailService, templateService, markdownService, testBaseURL, time.Hour, 230)
+	blogService = service.NewCachedBlogService(blogService, cache.NewMemoryStore(100), time.Minute)
+	privacyService := service.NewPrivacyService(s.subscribers, s.sends, s.suppressions, s.consents, mailService, templateService, testBaseURL, false, testSuppressionKey)
+	guard, err := service.NewSubscribeGuard(ratelimit.NewMemoryStore(), service.SubscribeGuardConfig{
+		BlockDisposable: true,
+		PowTTL:          time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	healthService := service.NewHealthService(time.Second,
+		service.HealthCheck{Name: "mongo", Critical: true, Check: func(context.Context) error {
+			if !s.mongoHealthy {
+				return errors.New("connection refused")
+			}
+			return nil
+		}},
+		service.HealthCheck{Name: "templates", Critical: true, Check: templateService.Check},
+	)
+
+	router := mux.NewRouter()
+	router.Use(middleware.BodyLimit(map[string]int64{"*": 1 << 20, "POST /admin/subscribers/import": 10 << 20}))
+	handler.NewHealthHandler(healthService).RegisterRoutes(router)
+	blogHandler := handler.NewBlogHandler(blogService, map[string]cache.Policy{
+		"*":               {MaxAge: time.Minute},
+		"GET /blogs/{id}": {MaxAge: 5 * time.Minute, StaleWhileRevalidate: time.Hour},
+	})
+	blogHandler.RegisterRoutes(router)
+	handler.NewSubscriberHandler(subscriberService, guard).RegisterRoutes(router)
+	handler.NewContentHandler(markdownService).RegisterRoutes(router)
+	privacyHandler := handler.NewPrivacyHandler(privacyService, templateService)
+	privacyHandler.RegisterRoutes(router)
+
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(handler.AdminAuth(testAdminToken))
+	blogHandler.RegisterAdminRoutes(admin)
+	handler.NewAdminSubscriberHandler(subscriberService).RegisterRoutes(admin)
+	privacyHandler.RegisterAdminRoutes(admin)
+
+	s.router = router
+	return s
+}
+
+// do serves a request with the given body. Header pairs follow the body.
+func (s *testServer) do(t *testing.T, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// admin is like do but authenticated with the admin token.
+func (s *testServer) admin(t *testing.T, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return s.do(t, method, target, body, append([]string{"Authorization", "Bearer " + testAdminToken}, header...)...)
+}
+
+type sentEmail struct {
+	to      []string
+	subject string
+	body    string
+}
+
+// captureSender records email instead of sending it.
+type captureSender struct {
+	sent chan sentEmail
+}
+
+func (c *captureSender) SendHTML(_ context.Context, to []string, subject, htmlBody string) error {
+	c.sent <- sentEmail{to: to, subject: subject, body: htmlBody}
+	return nil
+}
+
+// next waits for the next email to be sent.
+func (c *captureSender) next(t *testing.T) sentEmail {
+	t.Helper()
+	select {
+	case email := <-c.sent:
+		return email
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email was sent")
+		return sentEmail{}
+	}
+}
+
+// none checks that no email is waiting to be sent.
+func (c *captureSender) none(t *testing.T) {
+	t.Helper()
+	select {
+	case email := <-c.sent:
+		t.Fatalf("unexpected email %q to %v", email.subject, email.to)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func assertStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, want, rec.Body)
+	}
+}
+
+// decode unmarshals a JSON response body into v.
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body, err)
+	}
+}
+
+// assertProblem checks for an RFC 7807 response with the given status.
+func assertProblem(t *testing.T, rec *httptest.ResponseRecorder, want int) handler.Problem {
+	t.Helper()
+	assertStatus(t, rec, want)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", ct)
+	}
+	var p handler.Problem
+	decode(t, rec, &p)
+	if p.Status != want {
+		t.Errorf("problem status = %d, want %d", p.Status, want)
+	}
+	return p
+}
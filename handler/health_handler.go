@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/service"
+)
+
+type HealthHandler struct {
+	service service.HealthService
+}
+
+func NewHealthHandler(service service.HealthService) *HealthHandler {
+	return &HealthHandler{service: service}
+}
+
+// Liveness only reports that the process is serving requests; it never
+// touches dependencies, so a database outage does not get the server
+// restarted.
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]string{"status": string(service.HealthOK)})
+}
+
+// Readiness reports each dependency and returns 503 while any critical one
+// is failing or the server is shutting down.
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := h.service.Ready(r.Context())
+	status := http.StatusOK
+	if report.Status == service.HealthFail {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, report)
+}
+
+func writeHealth(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (h *HealthHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/healthz", h.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", h.Readiness).Methods("GET")
+}
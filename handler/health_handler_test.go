@@ -0,0 +1,31 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tahsin005/codercat-server/service"
+)
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(t, "GET", "/healthz", "")
+	assertStatus(t, rec, http.StatusOK)
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+
+	assertStatus(t, s.do(t, "GET", "/readyz", ""), http.StatusOK)
+
+	// Liveness ignores dependencies; readiness reports the failing one.
+	s.mongoHealthy = false
+	assertStatus(t, s.do(t, "GET", "/healthz", ""), http.StatusOK)
+	rec = s.do(t, "GET", "/readyz", "")
+	assertStatus(t, rec, http.StatusServiceUnavailable)
+	var report service.HealthReport
+	decode(t, rec, &report)
+	if report.Status != service.HealthFail || report.Components["mongo"].Status != service.HealthFail || report.Components["templates"].Status != service.HealthOK {
+		t.Errorf("report = %+v", report)
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/service"
+)
+
+type PrivacyHandler struct {
+	service         service.PrivacyService
+	templateService service.TemplateService
+}
+
+func NewPrivacyHandler(service service.PrivacyService, templateService service.TemplateService) *PrivacyHandler {
+	return &PrivacyHandler{service: service, templateService: templateService}
+}
+
+type accessRequest struct {
+	Email string `json:"email"`
+}
+
+// RequestAccess emails a subscriber links to their data. The response is
+// the same whether or not the address is subscribed.
+func (h *PrivacyHandler) RequestAccess(w http.ResponseWriter, r *http.Request) {
+	var req accessRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := h.service.RequestAccess(r.Context(), req.Email); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If this address is subscribed, we've emailed it a link to your data.",
+	})
+}
+
+func (h *PrivacyHandler) ExportData(w http.ResponseWriter, r *http.Request) {
+	data, err := h.service.ExportByToken(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeDataExport(w, data)
+}
+
+func (h *PrivacyHandler) EraseData(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.EraseByToken(r.Context(), r.URL.Query().Get("token")); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ConfirmErasePage is the target of the link in the data access email. It
+// only renders a confirmation form, so link scanners that follow the URL
+// cannot trigger an erasure.
+func (h *PrivacyHandler) ConfirmErasePage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	data, err := h.service.ExportByToken(r.Context(), token)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.writePage(w, r, "erase_confirm", map[string]string{
+		"Email":  data.Email,
+		"Token":  token,
+		"Action": r.URL.Path,
+	})
+}
+
+func (h *PrivacyHandler) SubmitErase(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.EraseByToken(r.Context(), r.PostFormValue("token")); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.writePage(w, r, "erase_done", nil)
+}
+
+func (h *PrivacyHandler) AdminExportData(w http.ResponseWriter, r *http.Request) {
+	data, err := h.service.ExportByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeDataExport(w, data)
+}
+
+func (h *PrivacyHandler) AdminEraseData(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.EraseByID(r.Context(), mux.Vars(r)["id"]); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *PrivacyHandler) writePage(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	page, err := h.templateService.RenderPageTemplate(name, data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(page))
+}
+
+func writeDataExport(w http.ResponseWriter, data *domain.SubscriberData) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="codercat-data.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(data)
+}
+
+func (h *PrivacyHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/privacy/request", h.RequestAccess).Methods("POST")
+	router.HandleFunc("/privacy/data", h.ExportData).Methods("GET")
+	router.HandleFunc("/privacy/data", h.EraseData).Methods("DELETE")
+	router.HandleFunc("/privacy/erase", h.ConfirmErasePage).Methods("GET")
+	router.HandleFunc("/privacy/erase", h.SubmitErase).Methods("POST")
+}
+
+// RegisterAdminRoutes registers the admin-triggered variants on a router
+// that is already protected by AdminAuth.
+func (h *PrivacyHandler) RegisterAdminRoutes(router *mux.Router) {
+	router.HandleFunc("/subscribers/{id}/data", h.AdminExportData).Methods("GET")
+	router.HandleFunc("/subscribers/{id}/data", h.AdminEraseData).Methods("DELETE")
+}
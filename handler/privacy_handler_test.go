@@ -0,0 +1,149 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/utils"
+)
+
+// subscribe adds a confirmed subscriber and returns it with its manage
+// token.
+func subscribe(t *testing.T, s *testServer, email string) *domain.Subscriber {
+	t.Helper()
+	importSubscribers(t, s, "email,status\n"+email+",confirmed\n")
+	sub, err := s.subscribers.FindByEmailKey(context.Background(), email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func assertErased(t *testing.T, s *testServer, sub *domain.Subscriber) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := s.subscribers.FindByID(ctx, sub.ID); err != domain.ErrNotFound {
+		t.Errorf("subscriber still exists: %v", err)
+	}
+	if found, _ := s.suppressions.FindExisting(ctx, []string{utils.EmailHash(testSuppressionKey, sub.EmailKey)}); len(found) != 1 {
+		t.Error("erased address was not suppressed")
+	}
+}
+
+func TestRequestAccess(t *testing.T) {
+	s := newTestServer(t)
+	sub := subscribe(t, s, "ada@example.com")
+
+	assertStatus(t, s.do(t, "POST", "/privacy/request", `{"email":"ada@example.com"}`), http.StatusAccepted)
+	email := s.mail.next(t)
+	token := url.QueryEscape(sub.ManageToken)
+	if !strings.Contains(email.body, testBaseURL+"/privacy/data?token="+token) || !strings.Contains(email.body, testBaseURL+"/privacy/erase?token="+token) {
+		t.Errorf("data access email does not link to the manage token")
+	}
+
+	// Unknown addresses get the same answer and no email.
+	assertStatus(t, s.do(t, "POST", "/privacy/request", `{"email":"nobody@example.com"}`), http.StatusAccepted)
+	s.mail.none(t)
+
+	assertProblem(t, s.do(t, "POST", "/privacy/request", `{"email":"nope"}`), http.StatusUnprocessableEntity)
+}
+
+func TestExportAndEraseByToken(t *testing.T) {
+	s := newTestServer(t)
+	sub := subscribe(t, s, "ada@example.com")
+	token := url.QueryEscape(sub.ManageToken)
+
+	rec := s.do(t, "GET", "/privacy/data?token="+token, "")
+	assertStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "attachment") || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+	var data domain.SubscriberData
+	decode(t, rec, &data)
+	if data.Email != "ada@example.com" || len(data.Consents) != 1 {
+		t.Errorf("export = %+v", data)
+	}
+
+	assertProblem(t, s.do(t, "GET", "/privacy/data?token=wrong", ""), http.StatusNotFound)
+	assertProblem(t, s.do(t, "GET", "/privacy/data", ""), http.StatusNotFound)
+
+	assertStatus(t, s.do(t, "DELETE", "/privacy/data?token="+token, ""), http.StatusNoContent)
+	assertErased(t, s, sub)
+
+	assertProblem(t, s.do(t, "DELETE", "/privacy/data?token="+token, ""), http.StatusNotFound)
+
+	// An admin import cannot bring an erased address back.
+	rec = s.admin(t, "POST", "/admin/subscribers/import", "email,status\nada@example.com,confirmed\n")
+	var report domain.ImportReport
+	decode(t, rec, &report)
+	if report.Imported != 0 || report.Suppressed != 1 {
+		t.Errorf("import report = %+v", report)
+	}
+
+	// The person themselves can, through double opt-in, and confirming
+	// lifts the suppression.
+	assertStatus(t, s.do(t, "POST", "/subscribe", `{"email":"Ada@example.com"}`), http.StatusAccepted)
+	s.mail.next(t)
+	again, err := s.subscribers.FindByEmailKey(context.Background(), sub.EmailKey)
+	if err != nil || again.Status != domain.SubscriberPending {
+		t.Fatalf("re-subscribed = %+v, %v", again, err)
+	}
+	assertStatus(t, s.do(t, "GET", "/subscribe/confirm?token="+url.QueryEscape(again.ConfirmToken), ""), http.StatusOK)
+	if found, _ := s.suppressions.FindExisting(context.Background(), []string{utils.EmailHash(testSuppressionKey, sub.EmailKey)}); len(found) != 0 {
+		t.Error("confirming did not lift the suppression")
+	}
+}
+
+func TestErasePages(t *testing.T) {
+	s := newTestServer(t)
+	sub := subscribe(t, s, "ada@example.com")
+	token := url.QueryEscape(sub.ManageToken)
+
+	// Following the emailed link only shows a form.
+	rec := s.do(t, "GET", "/privacy/erase?token="+token, "")
+	assertStatus(t, rec, http.StatusOK)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "ada@example.com") || !strings.Contains(body, sub.ManageToken) {
+		t.Errorf("confirmation page does not show the address and token")
+	}
+	if _, err := s.subscribers.FindByID(context.Background(), sub.ID); err != nil {
+		t.Fatalf("viewing the confirmation page erased the subscriber: %v", err)
+	}
+	assertProblem(t, s.do(t, "GET", "/privacy/erase?token=wrong", ""), http.StatusNotFound)
+
+	form := url.Values{"token": {sub.ManageToken}}.Encode()
+	rec = s.do(t, "POST", "/privacy/erase", form, "Content-Type", "application/x-www-form-urlencoded")
+	assertStatus(t, rec, http.StatusOK)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	assertErased(t, s, sub)
+	assertProblem(t, s.do(t, "POST", "/privacy/erase", form, "Content-Type", "application/x-www-form-urlencoded"), http.StatusNotFound)
+}
+
+func TestAdminDataRoutes(t *testing.T) {
+	s := newTestServer(t)
+	sub := subscribe(t, s, "ada@example.com")
+	path := "/admin/subscribers/" + sub.ID.Hex() + "/data"
+
+	assertProblem(t, s.do(t, "GET", path, ""), http.StatusUnauthorized)
+	rec := s.admin(t, "GET", path, "")
+	assertStatus(t, rec, http.StatusOK)
+	var data domain.SubscriberData
+	decode(t, rec, &data)
+	if data.Email != "ada@example.com" {
+		t.Errorf("export = %+v", data)
+	}
+
+	assertProblem(t, s.do(t, "DELETE", path, ""), http.StatusUnauthorized)
+	assertStatus(t, s.admin(t, "DELETE", path, ""), http.StatusNoContent)
+	assertErased(t, s, sub)
+	assertProblem(t, s.admin(t, "GET", path, ""), http.StatusNotFound)
+	assertProblem(t, s.admin(t, "DELETE", "/admin/subscribers/nope/data", ""), http.StatusBadRequest)
+}
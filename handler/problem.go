@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/logging"
+	"github.com/tahsin005/codercat-server/validation"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string                  `json:"type"`
+	Title    string                  `json:"title"`
+	Status   int                     `json:"status"`
+	Detail   string                  `json:"detail,omitempty"`
+	Instance string                  `json:"instance,omitempty"`
+	Errors   []validation.FieldError `json:"errors,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string, fieldErrors []validation.FieldError) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Errors:   fieldErrors,
+	})
+}
+
+// writeError maps an error returned by a service to a problem response.
+// Errors that are not recognised are logged and reported as a generic 500 so
+// that driver messages never reach the client.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErrors validation.Errors
+	var rateLimited *domain.RateLimitError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &fieldErrors):
+		writeProblem(w, r, http.StatusUnprocessableEntity, "The request contains invalid fields.", fieldErrors)
+	case errors.Is(err, domain.ErrInvalidID):
+		writeProblem(w, r, http.StatusBadRequest, "The resource ID is malformed.", nil)
+	case errors.Is(err, domain.ErrNotFound), errors.Is(err, mongo.ErrNoDocuments):
+		writeProblem(w, r, http.StatusNotFound, "The requested resource was not found.", nil)
+	case errors.Is(err, domain.ErrConflict), mongo.IsDuplicateKeyError(err):
+		writeProblem(w, r, http.StatusConflict, "The resource conflicts with an existing one.", nil)
+	case errors.As(err, &tooLarge):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("The request body exceeds %d bytes.", tooLarge.Limit), nil)
+	case errors.As(err, &rateLimited):
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(rateLimited.RetryAfter)))
+		writeProblem(w, r, http.StatusTooManyRequests, "Too many requests. Please try again later.", nil)
+	default:
+		logging.FromContext(r.Context()).ErrorContext(r.Context(), "request failed", logging.Err(err))
+		writeProblem(w, r, http.StatusInternalServerError, "An unexpected error occurred.", nil)
+	}
+}
+
+// retryAfterSeconds rounds d up to whole seconds, never below one.
+func retryAfterSeconds(d time.Duration) int {
+	secs := int((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
+
+// decodeJSON decodes the request body into v, writing a problem and
+// returning false when the body is missing, malformed, too large, or has
+// fields v does not know about.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after the JSON value")
+	}
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, io.EOF):
+		writeProblem(w, r, http.StatusBadRequest, "The request body is empty.", nil)
+	case errors.As(err, &tooLarge):
+		writeError(w, r, err)
+	default:
+		writeProblem(w, r, http.StatusBadRequest, "The request body is not valid JSON: "+err.Error(), nil)
+	}
+	return false
+}
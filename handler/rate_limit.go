@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/logging"
+	"github.com/tahsin005/codercat-server/middleware"
+	"github.com/tahsin005/codercat-server/ratelimit"
+)
+
+// RateLimit enforces per-route policies, looked up first by method and path
+// template, then by path template alone, then by the "*" policy. Every
+// limited response carries the RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy headers; refused requests get a 429
+// with Retry-After. Requests are let through if the store is unavailable.
+//
+// Policies keyed by token or API key only count a request against its
+// credential if it is one of the valid credentials of that kind, so that
+// sending a new made-up credential each time cannot escape the limit.
+func RateLimit(store ratelimit.Store, policies []ratelimit.Policy, credentials map[ratelimit.KeyKind][]string) mux.MiddlewareFunc {
+	byRoute := make(map[string]ratelimit.Policy, len(policies))
+	for _, p := range policies {
+		byRoute[p.Route] = p
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(byRoute) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy, ok := matchPolicy(byRoute, r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := "route:" + policy.Route + ":" + rateLimitKey(r, policy.Key, credentials[policy.Key])
+			res, err := store.Take(r.Context(), key, policy.Limit)
+			if err != nil {
+				logging.FromContext(r.Context()).WarnContext(r.Context(), "rate limit store unavailable", "key", key, logging.Err(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(retryAfterSeconds(res.ResetAfter)))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit.Burst, retryAfterSeconds(policyWindow(policy.Limit))))
+			if !res.Allowed {
+				writeError(w, r, &domain.RateLimitError{RetryAfter: res.RetryAfter})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func matchPolicy(byRoute map[string]ratelimit.Policy, r *http.Request) (ratelimit.Policy, bool) {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			if p, ok := byRoute[r.Method+" "+tmpl]; ok {
+				return p, true
+			}
+			if p, ok := byRoute[tmpl]; ok {
+				return p, true
+			}
+		}
+	}
+	p, ok := byRoute["*"]
+	return p, ok
+}
+
+// rateLimitKey identifies the caller for a policy. Credentials are hashed so
+// they are never written to the store, and callers without a valid one are
+// counted by address.
+func rateLimitKey(r *http.Request, kind ratelimit.KeyKind, valid []string) string {
+	var credential string
+	switch kind {
+	case ratelimit.KeyToken:
+		credential, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	case ratelimit.KeyAPIKey:
+		credential = r.Header.Get("X-API-Key")
+	}
+	if credential == "" || !validCredential(credential, valid) {
+		return "ip:" + middleware.ClientHost(r)
+	}
+	sum := sha256.Sum256([]byte(credential))
+	return string(kind) + ":" + hex.EncodeToString(sum[:16])
+}
+
+func validCredential(credential string, valid []string) bool {
+	ok := false
+	for _, v := range valid {
+		if v != "" && subtle.ConstantTimeCompare([]byte(credential), []byte(v)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// policyWindow is the time it takes an empty bucket to refill.
+func policyWindow(limit ratelimit.Limit) time.Duration {
+	return time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+}
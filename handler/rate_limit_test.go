@@ -0,0 +1,73 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/handler"
+	"github.com/tahsin005/codercat-server/ratelimit"
+)
+
+func TestRateLimitCredentials(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(handler.RateLimit(ratelimit.NewMemoryStore(), []ratelimit.Policy{
+		{Route: "GET /blogs/search", Limit: ratelimit.Per(2, time.Hour), Key: ratelimit.KeyToken},
+		{Route: "*", Limit: ratelimit.Per(2, time.Hour), Key: ratelimit.KeyAPIKey},
+	}, map[ratelimit.KeyKind][]string{
+		ratelimit.KeyToken:  {"valid-token"},
+		ratelimit.KeyAPIKey: {"key-1", "key-2"},
+	}))
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Handle("/blogs/search", ok).Methods("GET")
+	router.Handle("/blogs", ok).Methods("GET")
+
+	serve := func(target, header, value string) int {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		values []string
+	}{
+		{"bearer", "/blogs/search", "Authorization", []string{"Bearer valid-token", "Bearer made-up-"}},
+		{"api key", "/blogs", "X-API-Key", []string{"key-1", "made-up-"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, invalid := tt.values[0], tt.values[1]
+			// Made-up credentials share the client's address bucket, however
+			// many different ones are sent.
+			for i := range 3 {
+				want := http.StatusOK
+				if i == 2 {
+					want = http.StatusTooManyRequests
+				}
+				if got := serve(tt.target, tt.header, invalid+strconv.Itoa(i)); got != want {
+					t.Errorf("request %d with a made-up credential = %d, want %d", i+1, got, want)
+				}
+			}
+			if got := serve(tt.target, "", ""); got != http.StatusTooManyRequests {
+				t.Errorf("request without a credential = %d, want 429", got)
+			}
+			// A valid credential has a bucket of its own.
+			for i := range 2 {
+				if got := serve(tt.target, tt.header, valid); got != http.StatusOK {
+					t.Errorf("request %d with a valid credential = %d, want 200", i+1, got)
+				}
+			}
+		})
+	}
+}
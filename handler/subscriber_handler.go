@@ -2,38 +2,89 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
-	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/middleware"
 	"github.com/tahsin005/codercat-server/service"
 )
 
 type SubscriberHandler struct {
 	service service.SubscriberService
+	guard   service.SubscribeGuard
 }
 
-func NewSubscriberHandler(service service.SubscriberService) *SubscriberHandler {
-	return &SubscriberHandler{service: service}
+func NewSubscriberHandler(service service.SubscriberService, guard service.SubscribeGuard) *SubscriberHandler {
+	return &SubscriberHandler{service: service, guard: guard}
 }
 
+// subscribeRequest carries the honeypot in Website: the field is hidden
+// from people by the signup form, so only bots fill it in.
+type subscribeRequest struct {
+	Email          string `json:"email"`
+	Source         string `json:"source"`
+	ConsentVersion string `json:"consentVersion"`
+	Website        string `json:"website"`
+	Challenge      string `json:"challenge"`
+	Nonce          string `json:"nonce"`
+}
+
+// CreateSubscriber responds the same way whether the address is new,
+// pending or already confirmed, so the endpoint cannot be used to probe the
+// subscriber list.
 func (h *SubscriberHandler) CreateSubscriber(w http.ResponseWriter, r *http.Request) {
-	var subscriber domain.Subscriber
-	if err := json.NewDecoder(r.Body).Decode(&subscriber); err != nil || subscriber.Email == "" {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var req subscribeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	err := h.guard.Check(r.Context(), service.SubscribeAttempt{
+		Email:     req.Email,
+		IP:        middleware.ClientHost(r),
+		Honeypot:  req.Website,
+		Challenge: req.Challenge,
+		Nonce:     req.Nonce,
+	})
+	if err == nil {
+		err = h.service.Subscribe(r.Context(), req.Email, consentContext(r, req.Source, req.ConsentVersion))
+	}
+	if err != nil && !errors.Is(err, service.ErrSilentlyRejected) {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Thanks! Please check your inbox to confirm your subscription.",
+	})
+}
+
+// GetChallenge issues a proof-of-work challenge to solve before subscribing.
+func (h *SubscriberHandler) GetChallenge(w http.ResponseWriter, r *http.Request) {
+	challenge, err := h.guard.NewChallenge()
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
-	if err := h.service.CreateSubscriber(r.Context(), &subscriber); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(challenge)
+}
+
+func (h *SubscriberHandler) ConfirmSubscriber(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if err := h.service.Confirm(r.Context(), token, consentContext(r, "", "")); err != nil {
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Subscribed successfully",
+		"message": "Subscription confirmed",
 	})
 }
 
 func (h *SubscriberHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/subscribe", h.CreateSubscriber).Methods("POST")
+	router.HandleFunc("/subscribe/challenge", h.GetChallenge).Methods("GET")
+	router.HandleFunc("/subscribe/confirm", h.ConfirmSubscriber).Methods("GET")
 }
@@ -0,0 +1,104 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/service"
+)
+
+func TestSubscribeAndConfirm(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	rec := s.do(t, "POST", "/subscribe", `{"email":" Ada@Example.com ","source":"https://codercat.test/","consentVersion":"1"}`)
+	assertStatus(t, rec, http.StatusAccepted)
+	email := s.mail.next(t)
+	if len(email.to) != 1 || email.to[0] != "Ada@example.com" {
+		t.Errorf("confirmation sent to %v", email.to)
+	}
+	sub, err := s.subscribers.FindByEmailKey(ctx, "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Status != domain.SubscriberPending {
+		t.Errorf("status = %s, want pending", sub.Status)
+	}
+	if !strings.Contains(email.body, testBaseURL+"/subscribe/confirm?token="+sub.ConfirmToken) {
+		t.Errorf("confirmation email does not link to the confirm token")
+	}
+
+	// Subscribing again while pending re-sends the confirmation and looks
+	// the same to the client.
+	assertStatus(t, s.do(t, "POST", "/subscribe", `{"email":"ada@example.com"}`), http.StatusAccepted)
+	s.mail.next(t)
+
+	assertStatus(t, s.do(t, "GET", "/subscribe/confirm?token="+sub.ConfirmToken, ""), http.StatusOK)
+	sub, _ = s.subscribers.FindByID(ctx, sub.ID)
+	if sub.Status != domain.SubscriberConfirmed {
+		t.Errorf("status after confirm = %s", sub.Status)
+	}
+	consents, _ := s.consents.FindBySubscriber(ctx, sub.ID)
+	if len(consents) != 3 || consents[2].Action != domain.ConsentConfirm {
+		t.Fatalf("consent log = %+v", consents)
+	}
+	// The client's earlier but still known version is kept, and the
+	// current one is recorded when the client gives none.
+	if consents[0].TextVersion != "1" || consents[1].TextVersion != "2" {
+		t.Errorf("consent text versions = %q, %q", consents[0].TextVersion, consents[1].TextVersion)
+	}
+
+	// Once confirmed, subscribing is a silent no-op that leaves no trace in
+	// the consent log.
+	assertStatus(t, s.do(t, "POST", "/subscribe", `{"email":"ada@example.com"}`), http.StatusAccepted)
+	s.mail.none(t)
+	if consents, _ := s.consents.FindBySubscriber(ctx, sub.ID); len(consents) != 3 {
+		t.Errorf("consent log after subscribing again = %+v", consents)
+	}
+
+	assertProblem(t, s.do(t, "GET", "/subscribe/confirm?token="+sub.ConfirmToken, ""), http.StatusNotFound)
+	assertProblem(t, s.do(t, "GET", "/subscribe/confirm", ""), http.StatusNotFound)
+}
+
+func TestSubscribeRejections(t *testing.T) {
+	s := newTestServer(t)
+
+	// The honeypot gets the normal response but nothing happens.
+	assertStatus(t, s.do(t, "POST", "/subscribe", `{"email":"bot@example.com","website":"http://spam.test"}`), http.StatusAccepted)
+	s.mail.none(t)
+	if _, err := s.subscribers.FindByEmailKey(context.Background(), "bot@example.com"); err != domain.ErrNotFound {
+		t.Errorf("honeypot submission created a subscriber: %v", err)
+	}
+
+	for name, tt := range map[string]struct{ body, field string }{
+		"invalid email":           {`{"email":"not-an-email"}`, "email"},
+		"disposable email":        {`{"email":"someone@mailinator.com"}`, "email"},
+		"unknown consent version": {`{"email":"ada@example.com","consentVersion":"99"}`, "consentVersion"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := assertProblem(t, s.do(t, "POST", "/subscribe", tt.body), http.StatusUnprocessableEntity)
+			if len(p.Errors) == 0 || p.Errors[0].Field != tt.field {
+				t.Errorf("field errors = %+v", p.Errors)
+			}
+		})
+	}
+	assertProblem(t, s.do(t, "POST", "/subscribe", `{"email":"a@example.com","extra":true}`), http.StatusBadRequest)
+}
+
+func TestGetChallenge(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(t, "GET", "/subscribe/challenge", "")
+	assertStatus(t, rec, http.StatusOK)
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+	var challenge service.Challenge
+	decode(t, rec, &challenge)
+	if challenge.Challenge == "" || challenge.Algorithm == "" || challenge.ExpiresAt.IsZero() {
+		t.Errorf("challenge = %+v", challenge)
+	}
+}
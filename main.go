@@ -1,56 +1,126 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/tahsin005/codercat-server/cache"
 	"github.com/tahsin005/codercat-server/config"
-	"github.com/tahsin005/codercat-server/database"
 	"github.com/tahsin005/codercat-server/handler"
+	"github.com/tahsin005/codercat-server/logging"
+	"github.com/tahsin005/codercat-server/metrics"
+	"github.com/tahsin005/codercat-server/middleware"
+	"github.com/tahsin005/codercat-server/ratelimit"
 	"github.com/tahsin005/codercat-server/repository"
 	"github.com/tahsin005/codercat-server/service"
+	"github.com/tahsin005/codercat-server/tracing"
 	"github.com/tahsin005/codercat-server/utils"
 )
 
-// CORS middleware (open to all origins, no credentials)
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+func main() {
+	if err := godotenv.Load(); err != nil {
+		slog.Warn("failed to load .env file", logging.Err(err))
+	}
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+	cfg, err := config.LoadConfig()
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfig(cfg, err, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-		next.ServeHTTP(w, r)
-	})
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		slog.Error("failed to set up logging", logging.Err(err))
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	// run returns instead of exiting so that its deferred cleanup, including
+	// closing the database connection, always happens
+	if err := run(cfg, logger); err != nil {
+		slog.Error("server stopped", logging.Err(err))
+		os.Exit(1)
+	}
 }
 
-func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: Failed to load .env file: %v", err)
+func run(cfg *config.Config, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !cfg.MailEnabled {
+		slog.Warn("mail is disabled; emails will be logged instead of sent")
+	}
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		slog.Warn("CORS_ALLOWED_ORIGINS is empty; browsers will block cross-origin requests from any frontend")
 	}
 
-	cfg, err := config.LoadConfig()
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		ServiceName: cfg.TracingServiceName,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		return fmt.Errorf("failed to set up tracing: %w", err)
 	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("failed to flush traces", logging.Err(err))
+		}
+	}()
 
-	db, err := database.NewDatabase(cfg)
+	store, err := openStorage(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB Atlas: %v", err)
+		return err
+	}
+	defer store.close()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, store.migrator, os.Args[2:]); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		return nil
 	}
-	defer db.Disconnect()
-	log.Println("Connected to MongoDB Atlas")
 
-	blogRepo := repository.NewBlogRepository(db, cfg)
-	subscriberRepo := repository.NewSubscriberRepository(db, cfg)
-	subscriberService := service.NewSubscriberService(subscriberRepo)
+	if cfg.AutoMigrate {
+		applied, err := store.migrator.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to run migrations: %w", err)
+		}
+		if applied > 0 {
+			slog.Info("applied migrations", "count", applied)
+		}
+	}
+
+	var rateLimitStore ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "mongo":
+		rateLimitStore = ratelimit.NewMongoStore(store.mongo.DB.Collection(cfg.MongoCollNameRateLimits))
+	default:
+		rateLimitStore = ratelimit.NewMemoryStore()
+	}
+
+	blogRepo := repository.NewInstrumentedBlogRepository(store.blogs)
+	subscriberRepo := repository.NewInstrumentedSubscriberRepository(store.subscribers)
 	templateService := service.NewTemplateService("templates")
+	markdownService := service.NewMarkdownService()
 
 	emailCfg := utils.EmailConfig{
 		From:     cfg.SMTPEmail,
@@ -59,24 +129,194 @@ func main() {
 		SMTPPort: cfg.SMTPPort,
 	}
 
-	blogService := service.NewBlogService(blogRepo, subscriberService, emailCfg, templateService, cfg.BaseURL)
-	blogHandler := handler.NewBlogHandler(blogService)
-	subscriberHandler := handler.NewSubscriberHandler(subscriberService)
+	sendRepo := repository.NewInstrumentedSendRepository(store.sends)
+	suppressionRepo := repository.NewInstrumentedSuppressionRepository(store.suppressions)
+	consentRepo := repository.NewInstrumentedConsentRepository(store.consents)
+	sender := service.NewLogSender()
+	if cfg.MailEnabled {
+		sender = service.NewSMTPSender(emailCfg)
+	}
+	mailService := service.NewMailService(sendRepo, service.NewInstrumentedSender(sender))
+	subscriberService := service.NewSubscriberService(subscriberRepo, sendRepo, suppressionRepo, consentRepo, mailService, templateService, cfg.BaseURL, append([]string{cfg.ConsentTextVersion}, cfg.PriorConsentVersions...), cfg.CanonicalizeGmail, []byte(cfg.SuppressionKey))
 
-	router := mux.NewRouter()
+	blogService := service.NewBlogService(blogRepo, subscriberService, mailService, templateService, markdownService, cfg.BaseURL, cfg.TrashRetention, cfg.ReadingWPM)
+	if cfg.CacheTTL > 0 {
+		var cacheStore cache.Store
+		switch cfg.CacheStore {
+		case "mongo":
+			cacheStore = cache.NewMongoStore(store.mongo.DB.Collection(cfg.MongoCollNameCache))
+		default:
+			cacheStore = cache.NewMemoryStore(cfg.CacheMaxEntries)
+		}
+		blogService = service.NewCachedBlogService(blogService, cacheStore, cfg.CacheTTL)
+	}
+	blogService = service.NewTracedBlogService(blogService)
+
+	// Background workers run until shutdown begins and are waited for
+	// before the database is closed
+	workerCtx, stopWorkers := context.WithCancel(logging.WithLogger(context.Background(), logger.With("worker", "trash_purger")))
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	trashPurgerHeartbeat := service.NewHeartbeat(cfg.TrashPurgeInterval)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		service.RunTrashPurger(workerCtx, blogService, cfg.TrashPurgeInterval, trashPurgerHeartbeat)
+	}()
+
+	healthChecks := []service.HealthCheck{
+		{Name: cfg.DatabaseDriver, Critical: true, Check: store.ping},
+		{Name: "templates", Critical: true, Check: templateService.Check},
+		{Name: "trash_purger", Check: trashPurgerHeartbeat.Check},
+	}
+	if cfg.HealthCheckSMTP {
+		healthChecks = append(healthChecks, service.HealthCheck{
+			Name: "smtp",
+			Check: func(ctx context.Context) error {
+				return utils.CheckSMTP(ctx, emailCfg)
+			},
+		})
+	}
+	healthService := service.NewHealthService(cfg.HealthCheckTimeout, healthChecks...)
+
+	subscribeGuard, err := service.NewSubscribeGuard(rateLimitStore, service.SubscribeGuardConfig{
+		IPLimit:         cfg.SubscribeIPLimit,
+		DomainLimit:     cfg.SubscribeDomainLimit,
+		BlockDisposable: cfg.BlockDisposableEmails,
+		PowEnabled:      cfg.PowEnabled,
+		PowDifficulty:   cfg.PowDifficulty,
+		PowSecret:       []byte(cfg.PowSecret),
+		PowTTL:          cfg.PowTTL,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up subscribe guard: %w", err)
+	}
+
+	metrics.RegisterQueueDepth(mailService.QueueDepth)
+	metrics.RegisterCounts("subscribers", "Subscribers by status.", cfg.HealthCheckTimeout, func(ctx context.Context) (map[string]int64, error) {
+		counts, err := subscriberRepo.CountByStatus(ctx)
+		return statusCounts(counts), err
+	})
+	metrics.RegisterCounts("posts", "Blog posts by status.", cfg.HealthCheckTimeout, func(ctx context.Context) (map[string]int64, error) {
+		counts, err := blogRepo.CountByStatus(ctx)
+		return statusCounts(counts), err
+	})
 
-	// Apply CORS middleware to all routes
-	router.Use(corsMiddleware)
+	healthHandler := handler.NewHealthHandler(healthService)
+	blogHandler := handler.NewBlogHandler(blogService, cfg.CachePolicies)
+	subscriberHandler := handler.NewSubscriberHandler(subscriberService, subscribeGuard)
+	contentHandler := handler.NewContentHandler(markdownService)
+	adminSubscriberHandler := handler.NewAdminSubscriberHandler(subscriberService)
+	privacyService := service.NewPrivacyService(subscriberRepo, sendRepo, suppressionRepo, consentRepo, mailService, templateService, cfg.BaseURL, cfg.CanonicalizeGmail, []byte(cfg.SuppressionKey))
+	privacyHandler := handler.NewPrivacyHandler(privacyService, templateService)
 
+	router := mux.NewRouter()
+	router.Use(middleware.Tracing)
+	router.Use(middleware.Metrics)
+	router.Use(handler.RateLimit(rateLimitStore, cfg.RateLimitPolicies, map[ratelimit.KeyKind][]string{
+		ratelimit.KeyToken:  {cfg.AdminToken},
+		ratelimit.KeyAPIKey: cfg.RateLimitAPIKeys,
+	}))
+	router.Use(middleware.BodyLimit(cfg.BodyLimits))
+
+	healthHandler.RegisterRoutes(router)
+	metricsHandler := metrics.Handler()
+	if cfg.MetricsToken != "" {
+		metricsHandler = handler.AdminAuth(cfg.MetricsToken)(metricsHandler)
+	}
+	router.Handle("/metrics", metricsHandler).Methods("GET")
 	blogHandler.RegisterRoutes(router)
 	subscriberHandler.RegisterRoutes(router)
+	contentHandler.RegisterRoutes(router)
+	privacyHandler.RegisterRoutes(router)
+
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(handler.AdminAuth(cfg.AdminToken))
+	blogHandler.RegisterAdminRoutes(admin)
+	adminSubscriberHandler.RegisterRoutes(admin)
+	privacyHandler.RegisterAdminRoutes(admin)
 
-	router.Methods(http.MethodOptions).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
+	// CORS wraps the router so that preflights are answered before route
+	// matching, using the routes registered above
+	cors := middleware.CORS(middleware.CORSOptions{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		ExposedHeaders:   cfg.CORSExposedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	}, router)
+
+	security := middleware.SecurityHeaders(middleware.SecurityOptions{
+		ContentSecurityPolicy: cfg.ContentSecurityPolicy,
+		HSTSMaxAge:            cfg.HSTSMaxAge,
+		ReferrerPolicy:        cfg.ReferrerPolicy,
+		FrameOptions:          cfg.FrameOptions,
+	})
+
+	compress := middleware.Compress(middleware.CompressOptions{
+		Encodings:    cfg.CompressionEncodings,
+		MinSize:      int(cfg.CompressionMinSize),
+		ContentTypes: cfg.CompressionTypes,
 	})
 
-	log.Printf("Server starting on port %s", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, router); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	// Resolve the client address behind trusted proxies before anything
+	// records it, and tag the request before it is logged
+	var root http.Handler = compress(security(cors(router)))
+	root = middleware.AccessLog(router)(root)
+	root = middleware.RequestID(root)
+	root = middleware.RealIP(cfg.TrustedProxyHops)(root)
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           root,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server starting", "port", cfg.Port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Fail readiness first and give load balancers a moment to notice
+	// before the listener closes
+	healthService.SetShuttingDown()
+	if cfg.ShutdownDelay > 0 {
+		slog.Info("shutting down, waiting for traffic to move away", "delay", cfg.ShutdownDelay)
+		time.Sleep(cfg.ShutdownDelay)
+	}
+	slog.Info("shutting down, draining requests", "timeout", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("HTTP server did not drain cleanly", logging.Err(err))
+	}
+	stopWorkers()
+	workers.Wait()
+	if err := mailService.Close(shutdownCtx); err != nil {
+		slog.Warn("pending emails did not finish sending", logging.Err(err))
+	}
+	slog.Info("shutdown complete")
+	return nil
+}
+
+// statusCounts converts per-status counts to the string keys metrics uses.
+func statusCounts[S ~string](counts map[S]int64) map[string]int64 {
+	out := make(map[string]int64, len(counts))
+	for status, n := range counts {
+		out[string(status)] = n
 	}
+	return out
 }
@@ -0,0 +1,176 @@
+// Package metrics holds the server's Prometheus collectors. Everything is
+// registered on a private registry exposed by Handler so that tests and
+// libraries cannot pollute it.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/logging"
+)
+
+const namespace = "codercat"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	repositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_operation_duration_seconds",
+		Help:      "Repository method latency by repository, method and result.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"repository", "method", "result"})
+
+	emailSends = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_sends_total",
+		Help:      "Emails handed to the SMTP server by result.",
+	}, []string{"result"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Response cache lookups by result, hit or miss.",
+	}, []string{"result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		repositoryDuration,
+		emailSends,
+		cacheLookups,
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func ObserveHTTP(method, route string, status int, elapsed time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+}
+
+// ObserveRepository records a repository call, on whichever storage backend,
+// that started at start.
+func ObserveRepository(repository, method string, start time.Time, err error) {
+	repositoryDuration.WithLabelValues(repository, method, result(err)).Observe(time.Since(start).Seconds())
+}
+
+func ObserveEmail(err error) {
+	emailSends.WithLabelValues(result(err)).Inc()
+}
+
+func ObserveCache(hit bool) {
+	if hit {
+		cacheLookups.WithLabelValues("hit").Inc()
+	} else {
+		cacheLookups.WithLabelValues("miss").Inc()
+	}
+}
+
+// RegisterQueueDepth exposes the number of emails waiting to be sent.
+func RegisterQueueDepth(depth func() int) {
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "email_queue_depth",
+		Help:      "Emails queued for sending.",
+	}, func() float64 { return float64(depth()) }))
+}
+
+// countsMaxAge is how long RegisterCounts reuses a count, so that frequent
+// or unauthenticated scrapes do not each query the database.
+const countsMaxAge = 30 * time.Second
+
+// RegisterCounts exposes a gauge per status, computed by count with the
+// given timeout and reused for countsMaxAge.
+func RegisterCounts(name, help string, timeout time.Duration, count func(ctx context.Context) (map[string]int64, error)) {
+	registry.MustRegister(&countCollector{
+		desc:    prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, []string{"status"}, nil),
+		timeout: timeout,
+		count:   count,
+	})
+}
+
+type countCollector struct {
+	desc    *prometheus.Desc
+	timeout time.Duration
+	count   func(ctx context.Context) (map[string]int64, error)
+
+	// mu is held while counting, so concurrent scrapes share one count.
+	mu        sync.Mutex
+	counts    map[string]int64
+	countedAt time.Time
+}
+
+func (c *countCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *countCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.current()
+	if err != nil {
+		slog.Error("failed to collect metric", "metric", c.desc.String(), logging.Err(err))
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for status, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), status)
+	}
+}
+
+// current returns the last counts if they are recent enough, and counts
+// again otherwise. Failures are not cached.
+func (c *countCollector) current() (map[string]int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts != nil && time.Since(c.countedAt) < countsMaxAge {
+		return c.counts, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	counts, err := c.count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.counts, c.countedAt = counts, time.Now()
+	return counts, nil
+}
+
+func result(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, domain.ErrNotFound):
+		return "not_found"
+	}
+	return "error"
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/logging"
+)
+
+// AccessLog logs one line per request once it completes. It runs outside
+// the router so that unmatched and rejected requests are logged too, and
+// looks up the route template from router itself.
+func AccessLog(router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := newStatusWriter(w)
+			next.ServeHTTP(sw, r)
+
+			route := "unmatched"
+			var match mux.RouteMatch
+			if router.Match(r, &match) && match.MatchErr == nil {
+				if tmpl, err := match.Route.GetPathTemplate(); err == nil {
+					route = tmpl
+				}
+			}
+
+			status := sw.Status()
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logging.FromContext(r.Context()).LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", route),
+				slog.Int("status", status),
+				slog.Int64("bytes", sw.bytes),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remote_ip", ClientHost(r)),
+				slog.String("user_agent", r.UserAgent()),
+			)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// brotliLevel trades some of Brotli's ratio for the speed that responses
+// compressed on every request need.
+const brotliLevel = 4
+
+// encoder is the part of the gzip, Brotli and zstd writers Compress uses.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoders pools the writers for each supported Content-Encoding, which
+// are expensive to allocate.
+var encoders = map[string]*sync.Pool{
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(nil, brotliLevel)
+	}},
+	"zstd": {New: func() any {
+		// The options are fixed and valid, so this cannot fail.
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+	"gzip": {New: func() any {
+		return gzip.NewWriter(nil)
+	}},
+}
+
+type CompressOptions struct {
+	// Encodings lists the Content-Encodings to offer, most preferred first,
+	// for when a client accepts several equally.
+	Encodings []string
+	// MinSize is the smallest body worth compressing, in bytes.
+	MinSize int
+	// ContentTypes lists the media types to compress. Others, such as
+	// images that are already compressed, are sent as they are.
+	ContentTypes []string
+}
+
+type compressor struct {
+	encodings    []string
+	minSize      int
+	contentTypes map[string]bool
+}
+
+// Compress encodes response bodies in the best encoding the client
+// accepts. Bodies are buffered up to MinSize before deciding, so that
+// small responses go out unchanged.
+func Compress(opts CompressOptions) func(http.Handler) http.Handler {
+	c := &compressor{
+		minSize:      opts.MinSize,
+		contentTypes: make(map[string]bool, len(opts.ContentTypes)),
+	}
+	for _, encoding := range opts.Encodings {
+		if encoders[encoding] != nil {
+			c.encodings = append(c.encodings, encoding)
+		}
+	}
+	for _, t := range opts.ContentTypes {
+		c.contentTypes[strings.ToLower(t)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(c.encodings) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := c.negotiate(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, compressor: c, encoding: encoding}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiate returns the offered encoding with the highest quality in an
+// Accept-Encoding header, preferring earlier offers on ties, or "" if the
+// client accepts none of them.
+func (c *compressor) negotiate(header string) string {
+	if header == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range c.encodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+func (c *compressor) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && c.contentTypes[mediaType]
+}
+
+// compressWriter holds back the body until it knows whether to compress
+// it, and then writes it through an encoder or unchanged.
+type compressWriter struct {
+	http.ResponseWriter
+	*compressor
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool
+	enc      encoder
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided || status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if len(w.buf)+len(b) < w.minSize {
+			w.buf = append(w.buf, b...)
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide sends the headers, compressing if the body is big enough and of
+// a compressible type, and then whatever was buffered.
+func (w *compressWriter) decide(bigEnough bool) error {
+	w.decided = true
+	if w.status == 0 {
+		// The handler wrote nothing; let net/http send its default.
+		return nil
+	}
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// net/http would sniff the type from the compressed bytes.
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	compress := bigEnough && bodyAllowed(w.status) && h.Get("Content-Encoding") == "" && w.compressible(h.Get("Content-Type"))
+	// An encoded body is a different representation, so a strong ETag
+	// would be wrong for it, and a 304 stands in for one. The weak ETag
+	// still matches If-None-Match.
+	if etag := h.Get("ETag"); (compress || w.status == http.StatusNotModified) && strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", "W/"+etag)
+	}
+	if compress {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		w.enc = encoders[w.encoding].Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// Flush sends what has been written so far, compressing it if the body
+// has already reached MinSize.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(len(w.buf) >= w.minSize)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close finishes the response and returns the encoder to its pool.
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(len(w.buf) > 0 && len(w.buf) >= w.minSize)
+	}
+	if w.enc == nil {
+		return
+	}
+	w.enc.Close()
+	w.enc.Reset(io.Discard)
+	encoders[w.encoding].Put(w.enc)
+	w.enc = nil
+}
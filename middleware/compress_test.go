@@ -0,0 +1,114 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/tahsin005/codercat-server/middleware"
+)
+
+func TestCompress(t *testing.T) {
+	large := `{"content":"` + strings.Repeat("compressible ", 200) + `"}`
+	compress := middleware.Compress(middleware.CompressOptions{
+		Encodings:    []string{"br", "zstd", "gzip"},
+		MinSize:      1024,
+		ContentTypes: []string{"application/json"},
+	})
+	serve := func(t *testing.T, acceptEncoding, contentType, body string, status int) *httptest.ResponseRecorder {
+		t.Helper()
+		h := compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("ETag", `"v1"`)
+			w.WriteHeader(status)
+			// Write in pieces to exercise the buffering.
+			for len(body) > 0 {
+				n := min(len(body), 100)
+				w.Write([]byte(body[:n]))
+				body = body[n:]
+			}
+		}))
+		req := httptest.NewRequest("GET", "/", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Vary = %q", rec.Header().Get("Vary"))
+		}
+		return rec
+	}
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+	negotiation := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"gzip", "gzip"},
+		{"gzip, deflate, br, zstd", "br"},
+		{"gzip;q=0.5, zstd;q=0.8, br;q=0.1", "zstd"},
+		{"br;q=0, *", "zstd"},
+		{"deflate", ""},
+		{"*;q=0", ""},
+		{"", ""},
+	}
+	for _, tt := range negotiation {
+		t.Run("Accept-Encoding="+tt.acceptEncoding, func(t *testing.T) {
+			// Twice, so that the second request reuses a pooled encoder.
+			for range 2 {
+				rec := serve(t, tt.acceptEncoding, "application/json", large, http.StatusOK)
+				if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+					t.Fatalf("Content-Encoding = %q, want %q", got, tt.want)
+				}
+				body := io.Reader(rec.Body)
+				wantETag := `"v1"`
+				if tt.want != "" {
+					var err error
+					if body, err = decoders[tt.want](body); err != nil {
+						t.Fatal(err)
+					}
+					wantETag = `W/"v1"`
+				}
+				got, err := io.ReadAll(body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != large {
+					t.Errorf("body = %.40q..., want the original", got)
+				}
+				if etag := rec.Header().Get("ETag"); etag != wantETag {
+					t.Errorf("ETag = %q, want %q", etag, wantETag)
+				}
+			}
+		})
+	}
+
+	passThrough := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"small", "application/json", `{"ok":true}`, http.StatusOK},
+		{"other type", "image/png", large, http.StatusOK},
+		{"not modified", "application/json", "", http.StatusNotModified},
+	}
+	for _, tt := range passThrough {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, "gzip", tt.contentType, tt.body, tt.status)
+			if rec.Code != tt.status || rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != tt.body {
+				t.Errorf("got %d, Content-Encoding %q, body %.40q", rec.Code, rec.Header().Get("Content-Encoding"), rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// corsMethods are the methods offered in preflight responses when a route
+// serves them.
+var corsMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+type CORSOptions struct {
+	// AllowedOrigins lists exact origins such as "https://codercat.dev",
+	// subdomain patterns such as "https://*.codercat.dev", or "*" for any
+	// origin. When it is empty no cross-origin requests are allowed.
+	AllowedOrigins   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+type cors struct {
+	router         *mux.Router
+	anyOrigin      bool
+	origins        map[string]bool
+	patterns       []originPattern
+	allowedHeaders map[string]bool
+	exposedHeaders string
+	credentials    bool
+	maxAge         string
+}
+
+type originPattern struct {
+	scheme string
+	// suffix is the host name suffix including the leading dot, e.g.
+	// ".codercat.dev".
+	suffix string
+	// port must match when the pattern names one; otherwise any port does.
+	port string
+}
+
+// CORS applies opts to cross-origin requests. Preflights are answered
+// before they reach the router; the methods offered are the ones router
+// actually serves for the requested path, and preflights asking for an
+// unknown origin, method or header are refused with 403.
+func CORS(opts CORSOptions, router *mux.Router) func(http.Handler) http.Handler {
+	c := &cors{
+		router:         router,
+		origins:        make(map[string]bool),
+		allowedHeaders: make(map[string]bool),
+		exposedHeaders: strings.Join(opts.ExposedHeaders, ", "),
+		credentials:    opts.AllowCredentials,
+	}
+	for _, o := range opts.AllowedOrigins {
+		o = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(o), "/"))
+		switch {
+		case o == "*":
+			c.anyOrigin = true
+		case strings.Contains(o, "://*."):
+			scheme, host, _ := strings.Cut(o, "://*")
+			p := originPattern{scheme: scheme, suffix: host}
+			if name, port, err := net.SplitHostPort(host); err == nil {
+				p.suffix, p.port = name, port
+			}
+			c.patterns = append(c.patterns, p)
+		case o != "":
+			c.origins[o] = true
+		}
+	}
+	if c.anyOrigin && c.credentials {
+		slog.Warn("CORS credentials cannot be combined with a wildcard origin and are disabled")
+		c.credentials = false
+	}
+	for _, h := range opts.AllowedHeaders {
+		c.allowedHeaders[http.CanonicalHeaderKey(strings.TrimSpace(h))] = true
+	}
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if !c.anyOrigin {
+				w.Header().Add("Vary", "Origin")
+			}
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if preflight {
+				c.preflight(w, r, origin)
+				return
+			}
+			if c.allowOrigin(origin) {
+				c.setOrigin(w, origin)
+				if c.exposedHeaders != "" {
+					w.Header().Set("Access-Control-Expose-Headers", c.exposedHeaders)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	if !c.allowOrigin(origin) {
+		http.Error(w, "CORS origin not allowed", http.StatusForbidden)
+		return
+	}
+	methods := c.routeMethods(r)
+	if !slices.Contains(methods, r.Header.Get("Access-Control-Request-Method")) {
+		http.Error(w, "CORS method not allowed", http.StatusForbidden)
+		return
+	}
+	var headers []string
+	for _, v := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if !c.allowedHeaders[name] {
+				http.Error(w, "CORS header not allowed", http.StatusForbidden)
+				return
+			}
+			headers = append(headers, name)
+		}
+	}
+
+	c.setOrigin(w, origin)
+	h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(headers) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *cors) setOrigin(w http.ResponseWriter, origin string) {
+	if c.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if c.origins[origin] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := u.Hostname()
+	for _, p := range c.patterns {
+		if u.Scheme == p.scheme && strings.HasSuffix(host, p.suffix) && len(host) > len(p.suffix) && (p.port == "" || u.Port() == p.port) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeMethods returns the methods the router serves for the request path.
+func (c *cors) routeMethods(r *http.Request) []string {
+	var methods []string
+	for _, m := range corsMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = m
+		var match mux.RouteMatch
+		if c.router.Match(probe, &match) && match.MatchErr == nil {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
@@ -0,0 +1,161 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/middleware"
+)
+
+// corsServer wraps a router serving GET and POST /items and PUT
+// /items/{id} in CORS with opts.
+func corsServer(opts middleware.CORSOptions) http.Handler {
+	router := mux.NewRouter()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.HandleFunc("/items", ok).Methods("GET", "POST")
+	router.HandleFunc("/items/{id}", ok).Methods("PUT")
+	return middleware.CORS(opts, router)(router)
+}
+
+// corsRequest serves a request from origin. Header pairs follow it.
+func corsRequest(h http.Handler, method, target, origin string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflight(t *testing.T) {
+	h := corsServer(middleware.CORSOptions{
+		AllowedOrigins: []string{"https://codercat.dev"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	rec := corsRequest(h, "OPTIONS", "/items", "https://codercat.dev",
+		"Access-Control-Request-Method", "POST",
+		"Access-Control-Request-Headers", "content-type, authorization")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", rec.Code)
+	}
+	for name, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://codercat.dev",
+		"Access-Control-Allow-Methods": "GET, POST",
+		"Access-Control-Allow-Headers": "Content-Type, Authorization",
+		"Access-Control-Max-Age":       "600",
+	} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	vary := rec.Header().Values("Vary")
+	for _, want := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+		if !slices.Contains(vary, want) {
+			t.Errorf("Vary = %v, lacks %s", vary, want)
+		}
+	}
+
+	refused := []struct {
+		name   string
+		origin string
+		header []string
+	}{
+		{"unknown origin", "https://evil.example", []string{"Access-Control-Request-Method", "GET"}},
+		{"method the route does not serve", "https://codercat.dev", []string{"Access-Control-Request-Method", "DELETE"}},
+		{"header not allowed", "https://codercat.dev", []string{"Access-Control-Request-Method", "GET", "Access-Control-Request-Headers", "X-Secret"}},
+	}
+	for _, tt := range refused {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := corsRequest(h, "OPTIONS", "/items", tt.origin, tt.header...)
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want 403", rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q on a refused preflight", got)
+			}
+		})
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	h := corsServer(middleware.CORSOptions{
+		AllowedOrigins: []string{"https://codercat.dev", "https://*.codercat.dev", "http://*.localhost:3000"},
+		ExposedHeaders: []string{"Retry-After"},
+	})
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://codercat.dev", true},
+		{"https://blog.codercat.dev", true},
+		{"https://a.b.codercat.dev", true},
+		{"https://a.codercat.dev:8443", true},
+		{"HTTPS://Blog.CoderCat.dev", true},
+		{"http://blog.codercat.dev", false},
+		{"https://evilcodercat.dev", false},
+		{"https://codercat.dev.evil.example", false},
+		{"http://app.localhost:3000", true},
+		{"http://app.localhost:4000", false},
+		{"https://other.example", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			rec := corsRequest(h, "GET", "/items", tt.origin)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d; requests from other origins are still served", rec.Code)
+			}
+			got := rec.Header().Get("Access-Control-Allow-Origin")
+			if tt.allowed && (got != tt.origin || rec.Header().Get("Access-Control-Expose-Headers") != "Retry-After") {
+				t.Errorf("headers = %v, want the origin allowed", rec.Header())
+			}
+			if !tt.allowed && got != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+			}
+			if !slices.Contains(rec.Header().Values("Vary"), "Origin") {
+				t.Errorf("Vary = %v, lacks Origin", rec.Header().Values("Vary"))
+			}
+		})
+	}
+
+	rec := corsRequest(h, "GET", "/items", "")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" || !slices.Contains(rec.Header().Values("Vary"), "Origin") {
+		t.Errorf("same-origin headers = %v", rec.Header())
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	h := corsServer(middleware.CORSOptions{
+		AllowedOrigins:   []string{"https://codercat.dev"},
+		AllowCredentials: true,
+	})
+	rec := corsRequest(h, "GET", "/items", "https://codercat.dev")
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "true" || rec.Header().Get("Access-Control-Allow-Origin") != "https://codercat.dev" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+	rec = corsRequest(h, "GET", "/items", "https://other.example")
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("credentials allowed for an unknown origin: %v", rec.Header())
+	}
+
+	// Any origin rules out credentials, and the response no longer
+	// depends on the origin.
+	h = corsServer(middleware.CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	rec = corsRequest(h, "GET", "/items", "https://other.example")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+	if slices.Contains(rec.Header().Values("Vary"), "Origin") {
+		t.Errorf("Vary = %v with any origin allowed", rec.Header().Values("Vary"))
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tahsin005/codercat-server/metrics"
+)
+
+// Metrics records request counts and latency by route template, so that
+// "/blogs/{id}" is one series rather than one per post.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := newStatusWriter(w)
+		next.ServeHTTP(sw, r)
+		metrics.ObserveHTTP(r.Method, routeTemplate(r), sw.Status(), time.Since(start))
+	})
+}
+
+// routeTemplate returns the path template of the matched route, or
+// "unmatched".
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIP rewrites r.RemoteAddr to the client address reported by trusted
+// reverse proxies. hops is the number of proxies in front of the server;
+// each appends the address it received the request from to
+// X-Forwarded-For, so the client is the entry hops positions from the end.
+// Entries further left are supplied by the client and cannot be trusted.
+// With hops set to zero the header is ignored.
+func RealIP(hops int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if hops <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := forwardedFor(r.Header.Values("X-Forwarded-For"), hops); ip != "" {
+				r.RemoteAddr = net.JoinHostPort(ip, "0")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func forwardedFor(headers []string, hops int) string {
+	var addrs []string
+	for _, h := range headers {
+		for _, a := range strings.Split(h, ",") {
+			addrs = append(addrs, strings.TrimSpace(a))
+		}
+	}
+	if len(addrs) == 0 {
+		return ""
+	}
+	i := len(addrs) - hops
+	if i < 0 {
+		i = 0
+	}
+	if net.ParseIP(addrs[i]) == nil {
+		return ""
+	}
+	return addrs[i]
+}
+
+// ClientHost returns the host part of r.RemoteAddr, as resolved by RealIP.
+func ClientHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,50 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tahsin005/codercat-server/middleware"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	serve := func(opts middleware.SecurityOptions) http.Header {
+		h := middleware.SecurityHeaders(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		return rec.Header()
+	}
+
+	header := serve(middleware.SecurityOptions{
+		ContentSecurityPolicy: "default-src 'none'",
+		HSTSMaxAge:            180 * 24 * time.Hour,
+		ReferrerPolicy:        "no-referrer",
+		FrameOptions:          "DENY",
+	})
+	for name, want := range map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"Content-Security-Policy":   "default-src 'none'",
+		"Strict-Transport-Security": "max-age=15552000; includeSubDomains",
+		"Referrer-Policy":           "no-referrer",
+		"X-Frame-Options":           "DENY",
+	} {
+		if got := header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// Empty options are left out, but nosniff is always sent.
+	header = serve(middleware.SecurityOptions{})
+	for _, name := range []string{"Content-Security-Policy", "Strict-Transport-Security", "Referrer-Policy", "X-Frame-Options"} {
+		if got, ok := header[name]; ok {
+			t.Errorf("%s = %q, want it left out", name, got)
+		}
+	}
+	if got := header.Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q", got)
+	}
+}
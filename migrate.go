@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/tahsin005/codercat-server/migration"
+)
+
+const migrateUsage = "usage: codercat-server migrate [up | down [steps] | status]"
+
+// runMigrate implements the "migrate" subcommand.
+func runMigrate(ctx context.Context, runner migration.Migrator, args []string) error {
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "up":
+		n, err := runner.Up(ctx)
+		fmt.Printf("Applied %d migration(s)\n", n)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		n, err := runner.Down(ctx, steps)
+		fmt.Printf("Reverted %d migration(s)\n", n)
+		return err
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-24s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", cmd, migrateUsage)
+	}
+}
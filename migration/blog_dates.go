@@ -0,0 +1,64 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/logging"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// MigrateBlogDates converts the legacy free-form "date" string on blog posts
+// into publishedAt, and backfills createdAt and updatedAt from the document's
+// ObjectID. Posts whose date cannot be parsed fall back to the ObjectID time.
+// The original string is kept in legacyDate, so that nothing an author typed
+// is lost and the migration can be reverted exactly. Only documents without
+// createdAt are touched, so it is safe to re-run.
+func MigrateBlogDates(ctx context.Context, coll *mongo.Collection) (int, error) {
+	filter := bson.D{{Key: "createdAt", Value: bson.D{{Key: "$exists", Value: false}}}}
+	cursor, err := coll.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID   bson.ObjectID `bson:"_id"`
+			Date string        `bson:"date"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return migrated, err
+		}
+
+		created := doc.ID.Timestamp().UTC()
+		published := created
+		if doc.Date != "" {
+			if t, err := domain.ParseDate(doc.Date); err == nil {
+				published = t
+			} else {
+				logging.FromContext(ctx).WarnContext(ctx, "unparseable blog date, using creation time; kept in legacyDate", "blog_id", doc.ID.Hex(), logging.Err(err))
+			}
+		}
+
+		set := bson.D{
+			{Key: "createdAt", Value: created},
+			{Key: "updatedAt", Value: created},
+			{Key: "publishedAt", Value: published},
+		}
+		if doc.Date != "" {
+			set = append(set, bson.E{Key: "legacyDate", Value: doc.Date})
+		}
+		update := bson.D{
+			{Key: "$set", Value: set},
+			{Key: "$unset", Value: bson.D{{Key: "date", Value: ""}}},
+		}
+		if _, err := coll.UpdateByID(ctx, doc.ID, update); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, cursor.Err()
+}
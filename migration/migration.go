@@ -0,0 +1,270 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tahsin005/codercat-server/logging"
+	"github.com/tahsin005/codercat-server/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Collection is the collection, or on the SQL backends the table, that
+// records which migrations have been applied.
+const Collection = "schema_migrations"
+
+// lockCollection holds the lease that lets one instance at a time apply or
+// revert migrations, so that instances starting together with AUTO_MIGRATE
+// do not both run them.
+const lockCollection = Collection + "_lock"
+
+const (
+	lockID = "migrations"
+	// leaseDuration bounds how long a crashed instance can hold the lease.
+	// It is renewed before each migration, so it only has to outlast the
+	// slowest single one.
+	leaseDuration = 10 * time.Minute
+	lockRetry     = time.Second
+)
+
+// lease is the document in lockCollection while an instance migrates.
+type lease struct {
+	ID        string    `bson:"_id"`
+	Owner     string    `bson:"owner"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// Migration is a single, ordered schema change. Down reverses Up and may be
+// nil for migrations that cannot be undone.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// Status describes a known migration and when it was applied, if ever.
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// Migrator applies and reverts the migrations of one storage backend.
+// Runner serves Mongo and SQLRunner the SQL backends.
+type Migrator interface {
+	Up(ctx context.Context) (int, error)
+	Down(ctx context.Context, steps int) (int, error)
+	Status(ctx context.Context) ([]Status, error)
+}
+
+type record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+type Runner struct {
+	db         *mongo.Database
+	records    *mongo.Collection
+	lock       *mongo.Collection
+	migrations []Migration
+}
+
+func NewRunner(db *mongo.Database, migrations []Migration) *Runner {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Runner{
+		db:         db,
+		records:    db.Collection(Collection),
+		lock:       db.Collection(lockCollection),
+		migrations: sorted,
+	}
+}
+
+// Up applies every pending migration in version order and returns the
+// number applied. It stops at the first failure. If another instance is
+// migrating it waits for it to finish first.
+func (r *Runner) Up(ctx context.Context) (int, error) {
+	l, err := r.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer r.release(ctx, l)
+
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range r.migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := r.renew(ctx, l); err != nil {
+			return count, err
+		}
+		logging.FromContext(ctx).InfoContext(ctx, "applying migration", "version", m.Version, "name", m.Name)
+		if err := m.Up(ctx, r.db); err != nil {
+			return count, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		rec := record{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}
+		if _, err := r.records.InsertOne(ctx, rec); err != nil {
+			return count, fmt.Errorf("recording migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down reverts the most recently applied migrations, up to steps of them.
+func (r *Runner) Down(ctx context.Context, steps int) (int, error) {
+	l, err := r.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer r.release(ctx, l)
+
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(r.migrations) - 1; i >= 0 && count < steps; i-- {
+		m := r.migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == nil {
+			return count, fmt.Errorf("migration %d_%s cannot be reverted", m.Version, m.Name)
+		}
+		if err := r.renew(ctx, l); err != nil {
+			return count, err
+		}
+		logging.FromContext(ctx).InfoContext(ctx, "reverting migration", "version", m.Version, "name", m.Name)
+		if err := m.Down(ctx, r.db); err != nil {
+			return count, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		if _, err := r.records.DeleteOne(ctx, bson.D{{Key: "_id", Value: m.Version}}); err != nil {
+			return count, fmt.Errorf("unrecording migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(r.migrations))
+	for i, m := range r.migrations {
+		statuses[i] = Status{Version: m.Version, Name: m.Name}
+		if rec, ok := applied[m.Version]; ok {
+			at := rec.AppliedAt
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+func (r *Runner) applied(ctx context.Context) (map[int]record, error) {
+	cursor, err := r.records.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	applied := make(map[int]record)
+	for cursor.Next(ctx) {
+		var rec record
+		if err := cursor.Decode(&rec); err != nil {
+			return nil, err
+		}
+		applied[rec.Version] = rec
+	}
+	return applied, cursor.Err()
+}
+
+// acquire takes the migration lease, waiting while another instance holds
+// an unexpired one.
+func (r *Runner) acquire(ctx context.Context) (*lease, error) {
+	owner, err := utils.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	l := &lease{ID: lockID, Owner: owner}
+	waiting := false
+	for {
+		now := time.Now().UTC()
+		l.ExpiresAt = now.Add(leaseDuration)
+		// Matches only an expired lease; while another instance holds one
+		// the upsert collides with it on _id.
+		filter := bson.D{{Key: "_id", Value: lockID}, {Key: "expiresAt", Value: bson.D{{Key: "$lt", Value: now}}}}
+		_, err := r.lock.ReplaceOne(ctx, filter, l, options.Replace().SetUpsert(true))
+		if err == nil {
+			return l, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("taking the migration lock: %w", err)
+		}
+		if !waiting {
+			logging.FromContext(ctx).InfoContext(ctx, "waiting for another instance to finish migrating")
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetry):
+		}
+	}
+}
+
+// renew extends the lease before a migration, failing if it has expired
+// and been taken over.
+func (r *Runner) renew(ctx context.Context, l *lease) error {
+	l.ExpiresAt = time.Now().UTC().Add(leaseDuration)
+	res, err := r.lock.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: lockID}, {Key: "owner", Value: l.Owner}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "expiresAt", Value: l.ExpiresAt}}}})
+	if err != nil {
+		return fmt.Errorf("renewing the migration lock: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("lost the migration lock to another instance")
+	}
+	return nil
+}
+
+// release gives up the lease, even when ctx has been cancelled, so that
+// other instances need not wait for it to expire.
+func (r *Runner) release(ctx context.Context, l *lease) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if _, err := r.lock.DeleteOne(ctx, bson.D{{Key: "_id", Value: lockID}, {Key: "owner", Value: l.Owner}}); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "failed to release the migration lock", logging.Err(err))
+	}
+}
+
+// dropIndexes drops the named indexes on coll, ignoring ones that are absent.
+func dropIndexes(ctx context.Context, coll *mongo.Collection, names ...string) error {
+	for _, name := range names {
+		err := coll.Indexes().DropOne(ctx, name)
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Name == "IndexNotFound" {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
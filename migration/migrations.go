@@ -0,0 +1,372 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/tahsin005/codercat-server/config"
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/logging"
+	"github.com/tahsin005/codercat-server/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// All returns every migration known to the server, in version order.
+func All(cfg *config.Config) []Migration {
+	blogs := cfg.MongoCollNameBlogs
+	subscribers := cfg.MongoCollNameSubscribers
+	sends := cfg.MongoCollNameSends
+	consents := cfg.MongoCollNameConsents
+	rateLimits := cfg.MongoCollNameRateLimits
+	responseCache := cfg.MongoCollNameCache
+	// subscriberRefs are the collections that refer to subscribers by ID.
+	subscriberRefs := func(db *mongo.Database) []*mongo.Collection {
+		return []*mongo.Collection{db.Collection(sends), db.Collection(consents)}
+	}
+
+	return []Migration{
+		{
+			Version: 1,
+			Name:    "blog_dates",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := MigrateBlogDates(ctx, db.Collection(blogs))
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return revertBlogDates(ctx, db.Collection(blogs))
+			},
+		},
+		{
+			Version: 2,
+			Name:    "initial_indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				if err := createBlogIndexes(ctx, db.Collection(blogs)); err != nil {
+					return err
+				}
+				return createSubscriberEmailIndex(ctx, db.Collection(subscribers), subscriberRefs(db))
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				if err := dropIndexes(ctx, db.Collection(blogs), blogIndexNames...); err != nil {
+					return err
+				}
+				return dropIndexes(ctx, db.Collection(subscribers), "email_unique")
+			},
+		},
+		{
+			Version: 3,
+			Name:    "subscriber_email_keys",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				// emailKey_unique supersedes the raw email index, which
+				// could otherwise reject a normalized address mid-migration.
+				if err := dropIndexes(ctx, db.Collection(subscribers), "email_unique"); err != nil {
+					return err
+				}
+				return migrateSubscriberKeys(ctx, db.Collection(subscribers), subscriberRefs(db), cfg.CanonicalizeGmail)
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				if err := dropIndexes(ctx, db.Collection(subscribers), "emailKey_unique", "confirmToken"); err != nil {
+					return err
+				}
+				return createSubscriberEmailIndex(ctx, db.Collection(subscribers), subscriberRefs(db))
+			},
+		},
+		{
+			Version: 4,
+			Name:    "send_history_indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(subscribers).Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}},
+					Options: options.Index().SetName("status_createdAt"),
+				})
+				if err != nil {
+					return err
+				}
+				_, err = db.Collection(sends).Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "subscriberId", Value: 1}, {Key: "sentAt", Value: -1}},
+					Options: options.Index().SetName("subscriberId_sentAt"),
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				if err := dropIndexes(ctx, db.Collection(subscribers), "status_createdAt"); err != nil {
+					return err
+				}
+				return dropIndexes(ctx, db.Collection(sends), "subscriberId_sentAt")
+			},
+		},
+		{
+			Version: 5,
+			Name:    "subscriber_manage_tokens",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				return backfillManageTokens(ctx, db.Collection(subscribers))
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return dropIndexes(ctx, db.Collection(subscribers), "manageToken_unique")
+			},
+		},
+		{
+			Version: 6,
+			Name:    "consent_log_indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(consents).Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "subscriberId", Value: 1}, {Key: "createdAt", Value: 1}},
+					Options: options.Index().SetName("subscriberId_createdAt"),
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return dropIndexes(ctx, db.Collection(consents), "subscriberId_createdAt")
+			},
+		},
+		{
+			Version: 7,
+			Name:    "rate_limit_ttl",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(rateLimits).Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+					Options: options.Index().SetName("expiresAt_ttl").SetExpireAfterSeconds(0),
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return dropIndexes(ctx, db.Collection(rateLimits), "expiresAt_ttl")
+			},
+		},
+		{
+			Version: 8,
+			Name:    "cache_ttl",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(responseCache).Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+					Options: options.Index().SetName("expiresAt_ttl").SetExpireAfterSeconds(0),
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return dropIndexes(ctx, db.Collection(responseCache), "expiresAt_ttl")
+			},
+		},
+	}
+}
+
+// revertBlogDates restores the legacy date string kept in legacyDate, or
+// for posts created since the migration formats publishedAt, and removes
+// the fields added by MigrateBlogDates.
+func revertBlogDates(ctx context.Context, coll *mongo.Collection) error {
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{{Key: "date", Value: bson.D{
+			{Key: "$ifNull", Value: bson.A{
+				"$legacyDate",
+				bson.D{{Key: "$dateToString", Value: bson.D{
+					{Key: "date", Value: "$publishedAt"},
+					{Key: "format", Value: "%Y-%m-%d"},
+				}}},
+			}},
+		}}}}},
+		{{Key: "$unset", Value: bson.A{"createdAt", "updatedAt", "publishedAt", "legacyDate"}}},
+	}
+	_, err := coll.UpdateMany(ctx, bson.D{{Key: "publishedAt", Value: bson.D{{Key: "$exists", Value: true}}}}, update)
+	return err
+}
+
+var blogIndexNames = []string{"category", "tags", "publishedAt", "featured", "deletedAt", "text_search"}
+
+func createBlogIndexes(ctx context.Context, coll *mongo.Collection) error {
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "category", Value: 1}}, Options: options.Index().SetName("category")},
+		{Keys: bson.D{{Key: "tags", Value: 1}}, Options: options.Index().SetName("tags")},
+		{Keys: bson.D{{Key: "publishedAt", Value: -1}}, Options: options.Index().SetName("publishedAt")},
+		{Keys: bson.D{{Key: "featured", Value: 1}}, Options: options.Index().SetName("featured")},
+		{Keys: bson.D{{Key: "deletedAt", Value: 1}}, Options: options.Index().SetName("deletedAt")},
+		{
+			Keys: bson.D{
+				{Key: "title", Value: "text"},
+				{Key: "excerpt", Value: "text"},
+				{Key: "content", Value: "text"},
+				{Key: "tags", Value: "text"},
+			},
+			Options: options.Index().SetName("text_search").SetWeights(bson.D{
+				{Key: "title", Value: 10},
+				{Key: "tags", Value: 5},
+				{Key: "excerpt", Value: 3},
+				{Key: "content", Value: 1},
+			}),
+		},
+	}
+	_, err := coll.Indexes().CreateMany(ctx, models)
+	return err
+}
+
+// dedupeSubscribers merges each group of subscribers whose emails share a
+// key into its oldest confirmed member, or its oldest if none is confirmed.
+// Subscribers without a status predate double opt-in and count as
+// confirmed. The others' sends and consent records in referrers are moved
+// to the kept subscriber before the others are deleted, so a re-run after a
+// failure picks up where it stopped.
+func dedupeSubscribers(ctx context.Context, coll *mongo.Collection, referrers []*mongo.Collection, key func(email string) string) error {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetProjection(bson.D{{Key: "email", Value: 1}, {Key: "status", Value: 1}})
+	cursor, err := coll.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	type member struct {
+		ID     bson.ObjectID           `bson:"_id"`
+		Email  string                  `bson:"email"`
+		Status domain.SubscriberStatus `bson:"status"`
+	}
+	var keys []string
+	groups := make(map[string][]member)
+	for cursor.Next(ctx) {
+		var m member
+		if err := cursor.Decode(&m); err != nil {
+			return err
+		}
+		k := key(m.Email)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], m)
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		group := groups[k]
+		if len(group) < 2 {
+			continue
+		}
+		keep := group[0]
+		for _, m := range group {
+			if m.Status == "" || m.Status == domain.SubscriberConfirmed {
+				keep = m
+				break
+			}
+		}
+		var remove []bson.ObjectID
+		for _, m := range group {
+			if m.ID != keep.ID {
+				remove = append(remove, m.ID)
+			}
+		}
+
+		filter := bson.D{{Key: "subscriberId", Value: bson.D{{Key: "$in", Value: remove}}}}
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "subscriberId", Value: keep.ID}}}}
+		for _, ref := range referrers {
+			if _, err := ref.UpdateMany(ctx, filter, update); err != nil {
+				return err
+			}
+		}
+		if _, err := coll.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: remove}}}}); err != nil {
+			return err
+		}
+		logging.FromContext(ctx).InfoContext(ctx, "merged duplicate subscribers", "kept", keep.ID.Hex(), "removed", len(remove))
+	}
+	return nil
+}
+
+// createSubscriberEmailIndex enforces unique subscriber emails, first
+// merging subscribers that share one.
+func createSubscriberEmailIndex(ctx context.Context, coll *mongo.Collection, referrers []*mongo.Collection) error {
+	same := func(email string) string { return email }
+	if err := dedupeSubscribers(ctx, coll, referrers, same); err != nil {
+		return err
+	}
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetName("email_unique").SetUnique(true),
+	})
+	return err
+}
+
+// migrateSubscriberKeys normalizes existing subscriber emails, fills in
+// emailKey, status and createdAt and makes emailKey unique. Subscribers
+// that predate double opt-in are treated as confirmed. Subscribers that
+// turn out to share a mailbox are merged first.
+func migrateSubscriberKeys(ctx context.Context, coll *mongo.Collection, referrers []*mongo.Collection, gmail bool) error {
+	emailKey := func(email string) string { return utils.CanonicalEmail(utils.NormalizeEmail(email), gmail) }
+	if err := dedupeSubscribers(ctx, coll, referrers, emailKey); err != nil {
+		return err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := coll.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var sub domain.Subscriber
+		if err := cursor.Decode(&sub); err != nil {
+			return err
+		}
+		set := bson.D{{Key: "email", Value: utils.NormalizeEmail(sub.Email)}, {Key: "emailKey", Value: emailKey(sub.Email)}}
+		if sub.Status == "" {
+			set = append(set, bson.E{Key: "status", Value: domain.SubscriberConfirmed})
+		}
+		if sub.CreatedAt.IsZero() {
+			set = append(set, bson.E{Key: "createdAt", Value: sub.ID.Timestamp().UTC()})
+		}
+		if _, err := coll.UpdateByID(ctx, sub.ID, bson.D{{Key: "$set", Value: set}}); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	_, err = coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "emailKey", Value: 1}},
+			Options: options.Index().SetName("emailKey_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "confirmToken", Value: 1}},
+			Options: options.Index().SetName("confirmToken").SetSparse(true),
+		},
+	})
+	return err
+}
+
+// backfillManageTokens gives every existing subscriber a manage token for
+// self-service data requests and indexes it for lookup.
+func backfillManageTokens(ctx context.Context, coll *mongo.Collection) error {
+	filter := bson.D{{Key: "manageToken", Value: bson.D{{Key: "$exists", Value: false}}}}
+	cursor, err := coll.Find(ctx, filter, options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID bson.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		token, err := utils.NewToken()
+		if err != nil {
+			return err
+		}
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "manageToken", Value: token}}}}
+		if _, err := coll.UpdateByID(ctx, doc.ID, update); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "manageToken", Value: 1}},
+		Options: options.Index().SetName("manageToken_unique").SetUnique(true).SetSparse(true),
+	})
+	return err
+}
@@ -0,0 +1,190 @@
+package migration
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tahsin005/codercat-server/database"
+	"github.com/tahsin005/codercat-server/logging"
+)
+
+// sqlFiles holds the SQL backends' migrations as
+// sql/<dialect>/<version>_<name>.up.sql and a matching .down.sql.
+//
+//go:embed sql
+var sqlFiles embed.FS
+
+// sqlRecordTables create the table recording applied migrations.
+var sqlRecordTables = map[database.Dialect]string{
+	database.SQLite:   "CREATE TABLE IF NOT EXISTS " + Collection + " (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL)",
+	database.Postgres: "CREATE TABLE IF NOT EXISTS " + Collection + " (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMPTZ NOT NULL)",
+}
+
+type sqlMigration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SQLRunner is the Runner for the SQL backends. Each migration runs in a
+// transaction together with its record, so a failed one leaves no trace.
+type SQLRunner struct {
+	db         *database.SQLDatabase
+	migrations []sqlMigration
+}
+
+func NewSQLRunner(db *database.SQLDatabase) *SQLRunner {
+	return &SQLRunner{db: db, migrations: sqlMigrations(db.Dialect)}
+}
+
+// sqlMigrations reads the embedded migrations for dialect in version
+// order. The files are fixed at build time, so a malformed name is a
+// programming error.
+func sqlMigrations(dialect database.Dialect) []sqlMigration {
+	dir := path.Join("sql", string(dialect))
+	entries, err := fs.ReadDir(sqlFiles, dir)
+	if err != nil {
+		panic(fmt.Sprintf("migration: no SQL migrations for %s: %v", dialect, err))
+	}
+	byVersion := make(map[int]*sqlMigration)
+	for _, entry := range entries {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		version, name, _ := strings.Cut(base, "_")
+		n, err := strconv.Atoi(version)
+		if !ok || err != nil || (direction != "up" && direction != "down") {
+			panic(fmt.Sprintf("migration: malformed SQL migration file name %s", entry.Name()))
+		}
+		body, err := fs.ReadFile(sqlFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		m := byVersion[n]
+		if m == nil {
+			m = &sqlMigration{Version: n, Name: name}
+			byVersion[n] = m
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]sqlMigration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations
+}
+
+func (r *SQLRunner) Up(ctx context.Context) (int, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range r.migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		logging.FromContext(ctx).InfoContext(ctx, "applying migration", "version", m.Version, "name", m.Name)
+		err := r.db.InTx(ctx, func(tx *database.SQLTx) error {
+			if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO "+Collection+" (version, name, applied_at) VALUES (?, ?, ?)",
+				m.Version, m.Name, time.Now().UTC().Format(time.RFC3339Nano))
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (r *SQLRunner) Down(ctx context.Context, steps int) (int, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(r.migrations) - 1; i >= 0 && count < steps; i-- {
+		m := r.migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return count, fmt.Errorf("migration %d_%s cannot be reverted", m.Version, m.Name)
+		}
+		logging.FromContext(ctx).InfoContext(ctx, "reverting migration", "version", m.Version, "name", m.Name)
+		err := r.db.InTx(ctx, func(tx *database.SQLTx) error {
+			if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "DELETE FROM "+Collection+" WHERE version = ?", m.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (r *SQLRunner) Status(ctx context.Context) ([]Status, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(r.migrations))
+	for i, m := range r.migrations {
+		statuses[i] = Status{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// applied creates the record table if needed and returns when each
+// recorded migration was applied.
+func (r *SQLRunner) applied(ctx context.Context) (map[int]time.Time, error) {
+	if _, err := r.db.ExecContext(ctx, sqlRecordTables[r.db.Dialect]); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT version, applied_at FROM "+Collection)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt any
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		switch v := appliedAt.(type) {
+		case time.Time:
+			applied[version] = v.UTC()
+		case string:
+			applied[version], _ = time.Parse(time.RFC3339Nano, v)
+		}
+	}
+	return applied, rows.Err()
+}
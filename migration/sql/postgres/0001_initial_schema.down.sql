@@ -0,0 +1,7 @@
+DROP TABLE consents;
+DROP TABLE suppressions;
+DROP TABLE sends;
+DROP TABLE subscribers;
+DROP TABLE blog_tags;
+DROP TABLE tags;
+DROP TABLE blogs;
@@ -0,0 +1,91 @@
+-- IDs are the hex form of the ObjectIDs the Mongo backend uses.
+
+CREATE TABLE blogs (
+    id             TEXT PRIMARY KEY,
+    title          TEXT NOT NULL,
+    excerpt        TEXT NOT NULL,
+    content        TEXT NOT NULL,
+    content_html   TEXT NOT NULL,
+    toc            JSONB NOT NULL,
+    author         TEXT NOT NULL,
+    author_image   TEXT NOT NULL,
+    read_time      TEXT NOT NULL,
+    word_count     INTEGER NOT NULL,
+    category       TEXT NOT NULL,
+    image          TEXT NOT NULL,
+    featured       BOOLEAN NOT NULL,
+    excerpt_auto   BOOLEAN NOT NULL,
+    read_time_auto BOOLEAN NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL,
+    updated_at     TIMESTAMPTZ NOT NULL,
+    published_at   TIMESTAMPTZ,
+    deleted_at     TIMESTAMPTZ,
+    -- Weighted title (A), tags (B), excerpt (C) and content (D), written
+    -- by the repository, which knows each post's tags.
+    search         TSVECTOR NOT NULL DEFAULT ''
+);
+
+CREATE INDEX blogs_category ON blogs (category);
+CREATE INDEX blogs_published_at ON blogs (published_at);
+CREATE INDEX blogs_deleted_at ON blogs (deleted_at);
+CREATE INDEX blogs_search ON blogs USING GIN (search);
+
+CREATE TABLE tags (
+    id   BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE blog_tags (
+    blog_id  TEXT NOT NULL REFERENCES blogs (id) ON DELETE CASCADE,
+    tag_id   BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (blog_id, tag_id)
+);
+
+CREATE INDEX blog_tags_tag_id ON blog_tags (tag_id);
+
+CREATE TABLE subscribers (
+    id            TEXT PRIMARY KEY,
+    email         TEXT NOT NULL,
+    email_key     TEXT NOT NULL UNIQUE,
+    status        TEXT NOT NULL,
+    confirm_token TEXT,
+    manage_token  TEXT UNIQUE,
+    created_at    TIMESTAMPTZ NOT NULL,
+    confirmed_at  TIMESTAMPTZ
+);
+
+CREATE INDEX subscribers_confirm_token ON subscribers (confirm_token);
+CREATE INDEX subscribers_status_created_at ON subscribers (status, created_at);
+
+CREATE TABLE sends (
+    id            TEXT PRIMARY KEY,
+    subscriber_id TEXT NOT NULL,
+    kind          TEXT NOT NULL,
+    subject       TEXT NOT NULL,
+    blog_id       TEXT,
+    status        TEXT NOT NULL,
+    error         TEXT NOT NULL,
+    sent_at       TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX sends_subscriber_id_sent_at ON sends (subscriber_id, sent_at);
+
+CREATE TABLE suppressions (
+    hash       TEXT PRIMARY KEY,
+    reason     TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE consents (
+    id            TEXT PRIMARY KEY,
+    subscriber_id TEXT NOT NULL,
+    action        TEXT NOT NULL,
+    source        TEXT NOT NULL,
+    ip            TEXT NOT NULL,
+    user_agent    TEXT NOT NULL,
+    text_version  TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX consents_subscriber_id_created_at ON consents (subscriber_id, created_at);
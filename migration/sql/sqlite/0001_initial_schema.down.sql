@@ -0,0 +1,9 @@
+DROP TABLE consents;
+DROP TABLE suppressions;
+DROP TABLE sends;
+DROP TABLE subscribers;
+DROP TRIGGER blogs_fts_delete;
+DROP TABLE blogs_fts;
+DROP TABLE blog_tags;
+DROP TABLE tags;
+DROP TABLE blogs;
@@ -0,0 +1,103 @@
+-- Times are stored as fixed-width UTC text so that they sort and compare
+-- correctly. IDs are the hex form of the ObjectIDs the Mongo backend uses.
+
+CREATE TABLE blogs (
+    id             TEXT PRIMARY KEY,
+    title          TEXT NOT NULL,
+    excerpt        TEXT NOT NULL,
+    content        TEXT NOT NULL,
+    content_html   TEXT NOT NULL,
+    toc            TEXT NOT NULL,
+    author         TEXT NOT NULL,
+    author_image   TEXT NOT NULL,
+    read_time      TEXT NOT NULL,
+    word_count     INTEGER NOT NULL,
+    category       TEXT NOT NULL,
+    image          TEXT NOT NULL,
+    featured       INTEGER NOT NULL,
+    excerpt_auto   INTEGER NOT NULL,
+    read_time_auto INTEGER NOT NULL,
+    created_at     TEXT NOT NULL,
+    updated_at     TEXT NOT NULL,
+    published_at   TEXT,
+    deleted_at     TEXT
+);
+
+CREATE INDEX blogs_category ON blogs (category);
+CREATE INDEX blogs_published_at ON blogs (published_at);
+CREATE INDEX blogs_deleted_at ON blogs (deleted_at);
+
+CREATE TABLE tags (
+    id   INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE blog_tags (
+    blog_id  TEXT NOT NULL REFERENCES blogs (id) ON DELETE CASCADE,
+    tag_id   INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (blog_id, tag_id)
+);
+
+CREATE INDEX blog_tags_tag_id ON blog_tags (tag_id);
+
+-- The search index is written by the repository, which knows each post's
+-- tags, and cleaned up here when a post is purged.
+CREATE VIRTUAL TABLE blogs_fts USING fts5 (
+    blog_id UNINDEXED,
+    title,
+    tags,
+    excerpt,
+    content,
+    tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER blogs_fts_delete AFTER DELETE ON blogs BEGIN
+    DELETE FROM blogs_fts WHERE blog_id = old.id;
+END;
+
+CREATE TABLE subscribers (
+    id            TEXT PRIMARY KEY,
+    email         TEXT NOT NULL,
+    email_key     TEXT NOT NULL UNIQUE,
+    status        TEXT NOT NULL,
+    confirm_token TEXT,
+    manage_token  TEXT UNIQUE,
+    created_at    TEXT NOT NULL,
+    confirmed_at  TEXT
+);
+
+CREATE INDEX subscribers_confirm_token ON subscribers (confirm_token);
+CREATE INDEX subscribers_status_created_at ON subscribers (status, created_at);
+
+CREATE TABLE sends (
+    id            TEXT PRIMARY KEY,
+    subscriber_id TEXT NOT NULL,
+    kind          TEXT NOT NULL,
+    subject       TEXT NOT NULL,
+    blog_id       TEXT,
+    status        TEXT NOT NULL,
+    error         TEXT NOT NULL,
+    sent_at       TEXT NOT NULL
+);
+
+CREATE INDEX sends_subscriber_id_sent_at ON sends (subscriber_id, sent_at);
+
+CREATE TABLE suppressions (
+    hash       TEXT PRIMARY KEY,
+    reason     TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE TABLE consents (
+    id            TEXT PRIMARY KEY,
+    subscriber_id TEXT NOT NULL,
+    action        TEXT NOT NULL,
+    source        TEXT NOT NULL,
+    ip            TEXT NOT NULL,
+    user_agent    TEXT NOT NULL,
+    text_version  TEXT NOT NULL,
+    created_at    TEXT NOT NULL
+);
+
+CREATE INDEX consents_subscriber_id_created_at ON consents (subscriber_id, created_at);
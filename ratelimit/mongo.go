@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoStore is a Store shared by every server instance using the same
+// collection. Buckets are updated atomically with a pipeline update using the
+// database clock, and expire through a TTL index on expiresAt once they
+// would have refilled.
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoStore(collection *mongo.Collection) *MongoStore {
+	return &MongoStore{collection: collection}
+}
+
+func (s *MongoStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	res, err := s.take(ctx, key, limit)
+	// Two concurrent upserts of a new key can race on the _id index; the
+	// loser simply retries against the document the winner created.
+	if mongo.IsDuplicateKeyError(err) {
+		res, err = s.take(ctx, key, limit)
+	}
+	return res, err
+}
+
+func (s *MongoStore) take(ctx context.Context, key string, limit Limit) (Result, error) {
+	burst := float64(limit.Burst)
+	elapsedSeconds := bson.D{{Key: "$divide", Value: bson.A{
+		bson.D{{Key: "$subtract", Value: bson.A{"$$NOW", bson.D{{Key: "$ifNull", Value: bson.A{"$updatedAt", "$$NOW"}}}}}},
+		1000,
+	}}}
+	refillMillis := math.Ceil(burst / limit.Rate * 1000)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "tokens", Value: bson.D{{Key: "$min", Value: bson.A{
+				burst,
+				bson.D{{Key: "$add", Value: bson.A{
+					bson.D{{Key: "$ifNull", Value: bson.A{"$tokens", burst}}},
+					bson.D{{Key: "$multiply", Value: bson.A{elapsedSeconds, limit.Rate}}},
+				}}},
+			}}}},
+			{Key: "updatedAt", Value: "$$NOW"},
+		}}},
+		{{Key: "$set", Value: bson.D{
+			{Key: "allowed", Value: bson.D{{Key: "$gte", Value: bson.A{"$tokens", 1}}}},
+		}}},
+		{{Key: "$set", Value: bson.D{
+			{Key: "tokens", Value: bson.D{{Key: "$cond", Value: bson.A{
+				"$allowed",
+				bson.D{{Key: "$subtract", Value: bson.A{"$tokens", 1}}},
+				"$tokens",
+			}}}},
+			{Key: "expiresAt", Value: bson.D{{Key: "$add", Value: bson.A{"$$NOW", refillMillis}}}},
+		}}},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var doc struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	err := s.collection.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: key}}, pipeline, opts).Decode(&doc)
+	if err != nil {
+		return Result{}, err
+	}
+	return result(limit, doc.Tokens, doc.Allowed), nil
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyKind selects what a policy counts requests against.
+type KeyKind string
+
+const (
+	KeyIP     KeyKind = "ip"
+	KeyToken  KeyKind = "token"
+	KeyAPIKey KeyKind = "apikey"
+)
+
+// Policy applies a Limit to the requests matching Route. Route is either a
+// mux path template, optionally prefixed by a method ("GET /blogs/search"),
+// or "*" for routes without a policy of their own.
+type Policy struct {
+	Route string
+	Limit Limit
+	Key   KeyKind
+}
+
+// ParsePolicies parses a semicolon separated list of policies written as
+// "<route>=<limit>[@<key>]", for example
+//
+//	*=20/1s:40; GET /blogs/search=2/1s:10@ip; /admin/subscribers=5/1s@token
+//
+// The key defaults to ip.
+func ParsePolicies(s string) ([]Policy, error) {
+	var policies []Policy
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit policy %q: want <route>=<limit>", entry)
+		}
+		spec, key, hasKey := strings.Cut(spec, "@")
+		limit, err := ParseLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		policy := Policy{Route: NormalizeRoute(route), Limit: limit, Key: KeyIP}
+		if hasKey {
+			switch k := KeyKind(strings.ToLower(strings.TrimSpace(key))); k {
+			case KeyIP, KeyToken, KeyAPIKey:
+				policy.Key = k
+			default:
+				return nil, fmt.Errorf("invalid rate limit policy %q: unknown key %q", entry, key)
+			}
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// NormalizeRoute collapses whitespace and upper-cases the method of a route
+// written as "*", a path template or a method and path template, so that
+// routes compare equal however they were written.
+func NormalizeRoute(route string) string {
+	fields := strings.Fields(route)
+	if len(fields) == 2 {
+		return strings.ToUpper(fields[0]) + " " + fields[1]
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket: Burst tokens at most, refilled at Rate tokens
+// per second. Each request takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Per returns a Limit allowing n requests per period, all of which may be
+// used at once.
+func Per(n int, period time.Duration) Limit {
+	return Limit{Rate: float64(n) / period.Seconds(), Burst: n}
+}
+
+// ParseLimit parses limits written as "<requests>/<period>", such as
+// "5/1h" or "100/1m". An optional ":<burst>" suffix overrides the burst,
+// for example "10/1s:20".
+func ParseLimit(s string) (Limit, error) {
+	spec, burstStr, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	nStr, periodStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: want <requests>/<period>", s)
+	}
+	n, err := strconv.Atoi(nStr)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", s)
+	}
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+	limit := Per(n, period)
+	if hasBurst {
+		burst, err := strconv.Atoi(burstStr)
+		if err != nil || burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", s)
+		}
+		limit.Burst = burst
+	}
+	return limit, nil
+}
+
+// Result reports the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+	// RetryAfter is how long until a token is available; zero if Allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets keyed by an arbitrary string.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// result builds a Result from the tokens left in a bucket after a take.
+func result(limit Limit, tokens float64, allowed bool) Result {
+	res := Result{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Max(0, math.Floor(tokens))),
+		ResetAfter: seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -2,6 +2,9 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/tahsin005/codercat-server/config"
 	"github.com/tahsin005/codercat-server/database"
@@ -24,6 +27,15 @@ type BlogRepository interface {
 	FindRelated(ctx context.Context, blogID bson.ObjectID, limit int) ([]*domain.Blog, error)
 	GetCategories(ctx context.Context) ([]string, error)
 	GetPopularCategories(ctx context.Context, limit int) ([]string, error)
+	FindTrashed(ctx context.Context) ([]*domain.Blog, error)
+	Restore(ctx context.Context, id bson.ObjectID) error
+	Purge(ctx context.Context, id bson.ObjectID) error
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	CountByStatus(ctx context.Context) (map[domain.BlogStatus]int64, error)
+	// LastModified returns the latest time a post was created, changed,
+	// trashed, restored or published, so that it moves whenever any
+	// listing could change. It is zero when there are no posts.
+	LastModified(ctx context.Context) (time.Time, error)
 }
 
 type blogRepository struct {
@@ -36,35 +48,119 @@ func NewBlogRepository(db *database.Database, cfg *config.Config) BlogRepository
 	}
 }
 
+// notDeleted matches posts that have not been moved to the trash.
+var notDeleted = bson.E{Key: "deletedAt", Value: bson.D{{Key: "$exists", Value: false}}}
+
+// isDeleted matches posts that are currently in the trash.
+var isDeleted = bson.E{Key: "deletedAt", Value: bson.D{{Key: "$exists", Value: true}}}
+
 func (r *blogRepository) Create(ctx context.Context, blog *domain.Blog) error {
+	now := time.Now().UTC()
 	blog.ID = bson.NewObjectID()
+	blog.CreatedAt = now
+	blog.UpdatedAt = now
+	blog.DeletedAt = nil
 	_, err := r.collection.InsertOne(ctx, blog)
 	return err
 }
 
 func (r *blogRepository) FindByID(ctx context.Context, id bson.ObjectID) (*domain.Blog, error) {
 	var blog domain.Blog
-	err := r.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&blog)
-	if err == mongo.ErrNoDocuments {
+	err := r.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}, notDeleted}).Decode(&blog)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
 		return nil, err
 	}
-	return &blog, err
+	return &blog, nil
 }
 
 func (r *blogRepository) Update(ctx context.Context, id bson.ObjectID, blog *domain.Blog) error {
 	blog.ID = id
-	_, err := r.collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, bson.D{{Key: "$set", Value: blog}})
-	return err
+	blog.UpdatedAt = time.Now().UTC()
+	blog.DeletedAt = nil
+	res, err := r.collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}, notDeleted}, bson.D{{Key: "$set", Value: blog}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
 }
 
+// Delete moves a post to the trash by stamping deletedAt. Trashed posts are
+// invisible to every other query until restored or purged.
 func (r *blogRepository) Delete(ctx context.Context, id bson.ObjectID) error {
-	_, err := r.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
-	return err
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "deletedAt", Value: time.Now().UTC()}}}}
+	res, err := r.collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}, notDeleted}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *blogRepository) FindTrashed(ctx context.Context) ([]*domain.Blog, error) {
+	var blogs []*domain.Blog
+	opts := options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.D{isDeleted}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var blog domain.Blog
+		if err := cursor.Decode(&blog); err != nil {
+			return nil, err
+		}
+		blogs = append(blogs, &blog)
+	}
+	return blogs, cursor.Err()
+}
+
+func (r *blogRepository) Restore(ctx context.Context, id bson.ObjectID) error {
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "updatedAt", Value: time.Now().UTC()}}},
+		{Key: "$unset", Value: bson.D{{Key: "deletedAt", Value: ""}}},
+	}
+	res, err := r.collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}, isDeleted}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// Purge permanently removes a post. Only trashed posts can be purged.
+func (r *blogRepository) Purge(ctx context.Context, id bson.ObjectID) error {
+	res, err := r.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}, isDeleted})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *blogRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	filter := bson.D{{Key: "deletedAt", Value: bson.D{{Key: "$lt", Value: cutoff}}}}
+	res, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
 }
 
 func (r *blogRepository) FindAll(ctx context.Context) ([]*domain.Blog, error) {
 	var blogs []*domain.Blog
-	cursor, err := r.collection.Find(ctx, bson.D{})
+	cursor, err := r.collection.Find(ctx, bson.D{notDeleted})
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +177,7 @@ func (r *blogRepository) FindAll(ctx context.Context) ([]*domain.Blog, error) {
 
 func (r *blogRepository) FindFeatured(ctx context.Context) ([]*domain.Blog, error) {
 	var blogs []*domain.Blog
-	cursor, err := r.collection.Find(ctx, bson.D{{Key: "featured", Value: true}})
+	cursor, err := r.collection.Find(ctx, bson.D{{Key: "featured", Value: true}, notDeleted})
 	if err != nil {
 		return nil, err
 	}
@@ -98,8 +194,8 @@ func (r *blogRepository) FindFeatured(ctx context.Context) ([]*domain.Blog, erro
 
 func (r *blogRepository) FindRecent(ctx context.Context, limit int) ([]*domain.Blog, error) {
 	var blogs []*domain.Blog
-	opts := options.Find().SetSort(bson.D{{Key: "date", Value: -1}}).SetLimit(int64(limit))
-	cursor, err := r.collection.Find(ctx, bson.D{}, opts)
+	opts := options.Find().SetSort(bson.D{{Key: "publishedAt", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, bson.D{notDeleted}, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -116,9 +212,9 @@ func (r *blogRepository) FindRecent(ctx context.Context, limit int) ([]*domain.B
 
 func (r *blogRepository) FindByCategory(ctx context.Context, category string) ([]*domain.Blog, error) {
 	var blogs []*domain.Blog
-	filter := bson.D{}
+	filter := bson.D{notDeleted}
 	if category != "All" {
-		filter = bson.D{{Key: "category", Value: category}}
+		filter = append(filter, bson.E{Key: "category", Value: category})
 	}
 	cursor, err := r.collection.Find(ctx, filter)
 	if err != nil {
@@ -135,17 +231,21 @@ func (r *blogRepository) FindByCategory(ctx context.Context, category string) ([
 	return blogs, cursor.Err()
 }
 
+// Search matches posts against the blogs text index, best matches first.
+// An empty query returns every post. Unlike a regex scan it matches whole
+// (stemmed) words, not parts of them, and it fails until the migrations
+// have created the text_search index.
 func (r *blogRepository) Search(ctx context.Context, query string) ([]*domain.Blog, error) {
+	if strings.TrimSpace(query) == "" {
+		return r.FindAll(ctx)
+	}
 	var blogs []*domain.Blog
 	filter := bson.D{
-		{Key: "$or", Value: bson.A{
-			bson.D{{Key: "title", Value: bson.D{{Key: "$regex", Value: query}, {Key: "$options", Value: "i"}}}},
-			bson.D{{Key: "excerpt", Value: bson.D{{Key: "$regex", Value: query}, {Key: "$options", Value: "i"}}}},
-			bson.D{{Key: "content", Value: bson.D{{Key: "$regex", Value: query}, {Key: "$options", Value: "i"}}}},
-			bson.D{{Key: "tags", Value: bson.D{{Key: "$regex", Value: query}, {Key: "$options", Value: "i"}}}},
-		}},
+		{Key: "$text", Value: bson.D{{Key: "$search", Value: query}}},
+		notDeleted,
 	}
-	cursor, err := r.collection.Find(ctx, filter)
+	opts := options.Find().SetSort(bson.D{{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -172,6 +272,7 @@ func (r *blogRepository) FindRelated(ctx context.Context, blogID bson.ObjectID,
 			bson.D{{Key: "category", Value: currentBlog.Category}},
 			bson.D{{Key: "tags", Value: bson.D{{Key: "$in", Value: currentBlog.Tags}}}},
 		}},
+		notDeleted,
 	}
 	opts := options.Find().SetLimit(int64(limit))
 	cursor, err := r.collection.Find(ctx, filter, opts)
@@ -191,7 +292,7 @@ func (r *blogRepository) FindRelated(ctx context.Context, blogID bson.ObjectID,
 
 func (r *blogRepository) GetCategories(ctx context.Context) ([]string, error) {
 	var categoriesArr []string
-	err := r.collection.Distinct(ctx, "category", bson.D{}).Decode(&categoriesArr)
+	err := r.collection.Distinct(ctx, "category", bson.D{notDeleted}).Decode(&categoriesArr)
 	if err != nil {
 		return nil, err
 	}
@@ -201,6 +302,7 @@ func (r *blogRepository) GetCategories(ctx context.Context) ([]string, error) {
 
 func (r *blogRepository) GetPopularCategories(ctx context.Context, limit int) ([]string, error) {
 	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{notDeleted}}},
 		{{Key: "$group", Value: bson.D{
 			{Key: "_id", Value: "$category"},
 			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
@@ -229,3 +331,65 @@ func (r *blogRepository) GetPopularCategories(ctx context.Context, limit int) ([
 	}
 	return categories, nil
 }
+
+func (r *blogRepository) CountByStatus(ctx context.Context) (map[domain.BlogStatus]int64, error) {
+	scheduled := bson.E{Key: "publishedAt", Value: bson.D{{Key: "$gt", Value: time.Now().UTC()}}}
+	filters := map[domain.BlogStatus]bson.D{
+		domain.BlogScheduled: {notDeleted, scheduled},
+		domain.BlogTrashed:   {isDeleted},
+	}
+	counts := make(map[domain.BlogStatus]int64, 3)
+	for status, filter := range filters {
+		n, err := r.collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	live, err := r.collection.CountDocuments(ctx, bson.D{notDeleted})
+	if err != nil {
+		return nil, err
+	}
+	counts[domain.BlogPublished] = live - counts[domain.BlogScheduled]
+	return counts, nil
+}
+
+func (r *blogRepository) LastModified(ctx context.Context) (time.Time, error) {
+	published := bson.D{{Key: "$cond", Value: bson.A{
+		bson.D{{Key: "$lte", Value: bson.A{"$publishedAt", time.Now().UTC()}}},
+		"$publishedAt",
+		nil,
+	}}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "updatedAt", Value: bson.D{{Key: "$max", Value: "$updatedAt"}}},
+			{Key: "deletedAt", Value: bson.D{{Key: "$max", Value: "$deletedAt"}}},
+			{Key: "publishedAt", Value: bson.D{{Key: "$max", Value: published}}},
+		}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var results []struct {
+		UpdatedAt   *time.Time `bson:"updatedAt"`
+		DeletedAt   *time.Time `bson:"deletedAt"`
+		PublishedAt *time.Time `bson:"publishedAt"`
+	}
+	if err := cursor.All(ctx, &results); err != nil || len(results) == 0 {
+		return time.Time{}, err
+	}
+	return latest(results[0].UpdatedAt, results[0].DeletedAt, results[0].PublishedAt), nil
+}
+
+// latest returns the latest of times that are set, or the zero time.
+func latest(times ...*time.Time) time.Time {
+	var t time.Time
+	for _, v := range times {
+		if v != nil && v.After(t) {
+			t = v.UTC()
+		}
+	}
+	return t
+}
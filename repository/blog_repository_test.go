@@ -0,0 +1,321 @@
+package repository_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestBlogRepository(t *testing.T) {
+	run(t, backends(repository.NewMemoryBlogRepository, repository.NewBlogRepository, repository.NewSQLBlogRepository), testBlogRepository)
+}
+
+func testBlogRepository(t *testing.T, newRepo func(t *testing.T) repository.BlogRepository) {
+	ctx := context.Background()
+
+	// create stores a post and returns it as the repository saw it.
+	create := func(t *testing.T, repo repository.BlogRepository, title, category string, tags ...string) *domain.Blog {
+		t.Helper()
+		blog := &domain.Blog{
+			Title:       title,
+			Content:     "Body of " + title,
+			Author:      "Tester",
+			Category:    category,
+			Tags:        tags,
+			PublishedAt: date(2024, time.January, 1),
+		}
+		must(t, repo.Create(ctx, blog))
+		return blog
+	}
+	titles := func(blogs []*domain.Blog) []string {
+		out := make([]string, len(blogs))
+		for i, b := range blogs {
+			out[i] = b.Title
+		}
+		return out
+	}
+	sorted := func(s []string) []string {
+		s = slices.Clone(s)
+		slices.Sort(s)
+		return s
+	}
+
+	t.Run("CreateAndFindByID", func(t *testing.T) {
+		repo := newRepo(t)
+		blog := create(t, repo, "First", "Go", "go", "testing")
+		if blog.ID.IsZero() || blog.CreatedAt.IsZero() || blog.UpdatedAt.IsZero() {
+			t.Fatalf("Create did not set ID and timestamps: %+v", blog)
+		}
+
+		got, err := repo.FindByID(ctx, blog.ID)
+		must(t, err)
+		if got.Title != "First" || got.Category != "Go" || !slices.Equal(got.Tags, []string{"go", "testing"}) {
+			t.Errorf("FindByID = %+v", got)
+		}
+		if !got.PublishedAt.Equal(*blog.PublishedAt) {
+			t.Errorf("PublishedAt = %v, want %v", got.PublishedAt, blog.PublishedAt)
+		}
+
+		_, err = repo.FindByID(ctx, bson.NewObjectID())
+		assertErr(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repo := newRepo(t)
+		blog := create(t, repo, "Draft", "Go")
+
+		update := &domain.Blog{Title: "Final", Content: "New body", Category: "Rust", CreatedAt: blog.CreatedAt}
+		must(t, repo.Update(ctx, blog.ID, update))
+		got, err := repo.FindByID(ctx, blog.ID)
+		must(t, err)
+		if got.Title != "Final" || got.Category != "Rust" {
+			t.Errorf("FindByID after Update = %+v", got)
+		}
+		if got.PublishedAt == nil || !got.PublishedAt.Equal(*blog.PublishedAt) {
+			t.Errorf("Update without a publication date changed it to %v", got.PublishedAt)
+		}
+
+		assertErr(t, repo.Update(ctx, bson.NewObjectID(), update), domain.ErrNotFound)
+		must(t, repo.Delete(ctx, blog.ID))
+		assertErr(t, repo.Update(ctx, blog.ID, update), domain.ErrNotFound)
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		repo := newRepo(t)
+		kept := create(t, repo, "Kept", "Go")
+		trashed := create(t, repo, "Trashed", "Go")
+
+		must(t, repo.Delete(ctx, trashed.ID))
+		assertErr(t, repo.Delete(ctx, trashed.ID), domain.ErrNotFound)
+		_, err := repo.FindByID(ctx, trashed.ID)
+		assertErr(t, err, domain.ErrNotFound)
+		all, err := repo.FindAll(ctx)
+		must(t, err)
+		if got := titles(all); !slices.Equal(got, []string{"Kept"}) {
+			t.Errorf("FindAll = %v, want only the live post", got)
+		}
+		inTrash, err := repo.FindTrashed(ctx)
+		must(t, err)
+		if got := titles(inTrash); !slices.Equal(got, []string{"Trashed"}) || inTrash[0].DeletedAt == nil {
+			t.Errorf("FindTrashed = %v", got)
+		}
+
+		assertErr(t, repo.Restore(ctx, kept.ID), domain.ErrNotFound)
+		must(t, repo.Restore(ctx, trashed.ID))
+		if _, err := repo.FindByID(ctx, trashed.ID); err != nil {
+			t.Errorf("FindByID after Restore: %v", err)
+		}
+
+		assertErr(t, repo.Purge(ctx, kept.ID), domain.ErrNotFound)
+		must(t, repo.Delete(ctx, kept.ID))
+		must(t, repo.Purge(ctx, kept.ID))
+		assertErr(t, repo.Restore(ctx, kept.ID), domain.ErrNotFound)
+	})
+
+	t.Run("PurgeDeletedBefore", func(t *testing.T) {
+		repo := newRepo(t)
+		old := create(t, repo, "Old", "Go")
+		create(t, repo, "Live", "Go")
+		must(t, repo.Delete(ctx, old.ID))
+
+		n, err := repo.PurgeDeletedBefore(ctx, time.Now().Add(-time.Hour))
+		must(t, err)
+		if n != 0 {
+			t.Errorf("purged %d posts trashed after the cutoff", n)
+		}
+		n, err = repo.PurgeDeletedBefore(ctx, time.Now().Add(time.Hour))
+		must(t, err)
+		if n != 1 {
+			t.Errorf("purged %d posts, want 1", n)
+		}
+		trashed, err := repo.FindTrashed(ctx)
+		must(t, err)
+		if len(trashed) != 0 {
+			t.Errorf("FindTrashed after purge = %v", titles(trashed))
+		}
+	})
+
+	t.Run("FindFeatured", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "Plain", "Go")
+		featured := &domain.Blog{Title: "Star", Category: "Go", Featured: true}
+		must(t, repo.Create(ctx, featured))
+
+		blogs, err := repo.FindFeatured(ctx)
+		must(t, err)
+		if got := titles(blogs); !slices.Equal(got, []string{"Star"}) {
+			t.Errorf("FindFeatured = %v", got)
+		}
+	})
+
+	t.Run("FindRecent", func(t *testing.T) {
+		repo := newRepo(t)
+		for i, title := range []string{"Middle", "Newest", "Oldest"} {
+			day := []int{10, 20, 1}[i]
+			must(t, repo.Create(ctx, &domain.Blog{Title: title, Category: "Go", PublishedAt: date(2024, time.March, day)}))
+		}
+
+		blogs, err := repo.FindRecent(ctx, 2)
+		must(t, err)
+		if got := titles(blogs); !slices.Equal(got, []string{"Newest", "Middle"}) {
+			t.Errorf("FindRecent(2) = %v", got)
+		}
+	})
+
+	t.Run("FindByCategory", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "A", "Go")
+		create(t, repo, "B", "Rust")
+		create(t, repo, "C", "Go")
+
+		blogs, err := repo.FindByCategory(ctx, "Go")
+		must(t, err)
+		if got := sorted(titles(blogs)); !slices.Equal(got, []string{"A", "C"}) {
+			t.Errorf("FindByCategory(Go) = %v", got)
+		}
+		blogs, err = repo.FindByCategory(ctx, "All")
+		must(t, err)
+		if len(blogs) != 3 {
+			t.Errorf("FindByCategory(All) returned %d posts, want 3", len(blogs))
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		repo := newRepo(t)
+		must(t, repo.Create(ctx, &domain.Blog{Title: "Notes", Category: "Ops", Content: "We moved everything to kubernetes last year."}))
+		must(t, repo.Create(ctx, &domain.Blog{Title: "Kubernetes operators", Category: "Ops", Content: "Reconciliation loops."}))
+		must(t, repo.Create(ctx, &domain.Blog{Title: "Gardening", Category: "Life", Content: "Tomatoes."}))
+
+		blogs, err := repo.Search(ctx, "kubernetes")
+		must(t, err)
+		if got := titles(blogs); !slices.Equal(got, []string{"Kubernetes operators", "Notes"}) {
+			t.Errorf("Search = %v, want title matches ranked first", got)
+		}
+		blogs, err = repo.Search(ctx, "  ")
+		must(t, err)
+		if len(blogs) != 3 {
+			t.Errorf("empty Search returned %d posts, want 3", len(blogs))
+		}
+	})
+
+	t.Run("FindRelated", func(t *testing.T) {
+		repo := newRepo(t)
+		post := create(t, repo, "Post", "Go", "concurrency")
+		create(t, repo, "Same category", "Go")
+		create(t, repo, "Shared tag", "Rust", "concurrency")
+		create(t, repo, "Unrelated", "Rust", "macros")
+		trashed := create(t, repo, "Trashed", "Go")
+		must(t, repo.Delete(ctx, trashed.ID))
+
+		blogs, err := repo.FindRelated(ctx, post.ID, 10)
+		must(t, err)
+		if got := sorted(titles(blogs)); !slices.Equal(got, []string{"Same category", "Shared tag"}) {
+			t.Errorf("FindRelated = %v", got)
+		}
+		blogs, err = repo.FindRelated(ctx, post.ID, 1)
+		must(t, err)
+		if len(blogs) != 1 {
+			t.Errorf("FindRelated with limit 1 returned %d posts", len(blogs))
+		}
+		_, err = repo.FindRelated(ctx, bson.NewObjectID(), 10)
+		assertErr(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("GetCategories", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "A", "Go")
+		create(t, repo, "B", "Rust")
+		create(t, repo, "C", "Go")
+		trashed := create(t, repo, "D", "Zig")
+		must(t, repo.Delete(ctx, trashed.ID))
+
+		categories, err := repo.GetCategories(ctx)
+		must(t, err)
+		if len(categories) == 0 || categories[0] != "All" {
+			t.Fatalf("GetCategories = %v, want All first", categories)
+		}
+		if got := sorted(categories[1:]); !slices.Equal(got, []string{"Go", "Rust"}) {
+			t.Errorf("GetCategories = %v", categories)
+		}
+	})
+
+	t.Run("GetPopularCategories", func(t *testing.T) {
+		repo := newRepo(t)
+		for category, n := range map[string]int{"Go": 3, "Rust": 2, "Zig": 1} {
+			for range n {
+				create(t, repo, category+" post", category)
+			}
+		}
+
+		categories, err := repo.GetPopularCategories(ctx, 2)
+		must(t, err)
+		if !slices.Equal(categories, []string{"Go", "Rust"}) {
+			t.Errorf("GetPopularCategories(2) = %v", categories)
+		}
+	})
+
+	t.Run("CountByStatus", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "Published", "Go")
+		future := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+		must(t, repo.Create(ctx, &domain.Blog{Title: "Scheduled", Category: "Go", PublishedAt: &future}))
+		trashed := create(t, repo, "Trashed", "Go")
+		must(t, repo.Delete(ctx, trashed.ID))
+
+		counts, err := repo.CountByStatus(ctx)
+		must(t, err)
+		want := map[domain.BlogStatus]int64{domain.BlogPublished: 1, domain.BlogScheduled: 1, domain.BlogTrashed: 1}
+		for status, n := range want {
+			if counts[status] != n {
+				t.Errorf("CountByStatus()[%s] = %d, want %d", status, counts[status], n)
+			}
+		}
+	})
+
+	t.Run("LastModified", func(t *testing.T) {
+		repo := newRepo(t)
+		// advanced checks that a change moved LastModified forward. The
+		// pause keeps changes apart at Mongo's millisecond precision.
+		var last time.Time
+		advanced := func(t *testing.T, change string) {
+			t.Helper()
+			got, err := repo.LastModified(ctx)
+			must(t, err)
+			if !got.After(last) {
+				t.Errorf("LastModified after %s = %v, want after %v", change, got, last)
+			}
+			last = got
+			time.Sleep(2 * time.Millisecond)
+		}
+
+		got, err := repo.LastModified(ctx)
+		must(t, err)
+		if !got.IsZero() {
+			t.Fatalf("LastModified with no posts = %v", got)
+		}
+
+		blog := create(t, repo, "First", "Go")
+		advanced(t, "create")
+		must(t, repo.Update(ctx, blog.ID, &domain.Blog{Title: "First", Category: "Rust", PublishedAt: blog.PublishedAt}))
+		advanced(t, "update")
+		must(t, repo.Delete(ctx, blog.ID))
+		advanced(t, "delete")
+		must(t, repo.Restore(ctx, blog.ID))
+		advanced(t, "restore")
+
+		// A scheduled post counts once it is published.
+		soon := time.Now().Add(time.Second).UTC().Truncate(time.Millisecond)
+		must(t, repo.Create(ctx, &domain.Blog{Title: "Scheduled", Category: "Go", PublishedAt: &soon}))
+		time.Sleep(time.Until(soon) + 10*time.Millisecond)
+		got, err = repo.LastModified(ctx)
+		must(t, err)
+		if !got.Equal(soon) {
+			t.Errorf("LastModified after publication = %v, want %v", got, soon)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/tahsin005/codercat-server/config"
+	"github.com/tahsin005/codercat-server/database"
+	"github.com/tahsin005/codercat-server/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ConsentRepository stores the consent log. Records are never modified;
+// they are only removed together with the subscriber on erasure.
+type ConsentRepository interface {
+	Create(ctx context.Context, record *domain.ConsentRecord) error
+	FindBySubscriber(ctx context.Context, subscriberID bson.ObjectID) ([]*domain.ConsentRecord, error)
+	FindBySubscribers(ctx context.Context, subscriberIDs []bson.ObjectID) ([]*domain.ConsentRecord, error)
+	DeleteBySubscriber(ctx context.Context, subscriberID bson.ObjectID) error
+}
+
+type consentRepository struct {
+	collection *mongo.Collection
+}
+
+func NewConsentRepository(db *database.Database, cfg *config.Config) ConsentRepository {
+	return &consentRepository{
+		collection: db.DB.Collection(cfg.MongoCollNameConsents),
+	}
+}
+
+func (r *consentRepository) Create(ctx context.Context, record *domain.ConsentRecord) error {
+	record.ID = bson.NewObjectID()
+	_, err := r.collection.InsertOne(ctx, record)
+	return err
+}
+
+func (r *consentRepository) FindBySubscriber(ctx context.Context, subscriberID bson.ObjectID) ([]*domain.ConsentRecord, error) {
+	return r.find(ctx, bson.D{{Key: "subscriberId", Value: subscriberID}})
+}
+
+func (r *consentRepository) FindBySubscribers(ctx context.Context, subscriberIDs []bson.ObjectID) ([]*domain.ConsentRecord, error) {
+	return r.find(ctx, bson.D{{Key: "subscriberId", Value: bson.D{{Key: "$in", Value: subscriberIDs}}}})
+}
+
+func (r *consentRepository) DeleteBySubscriber(ctx context.Context, subscriberID bson.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.D{{Key: "subscriberId", Value: subscriberID}})
+	return err
+}
+
+// find returns matching records oldest first, the order they were logged.
+func (r *consentRepository) find(ctx context.Context, filter bson.D) ([]*domain.ConsentRecord, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []*domain.ConsentRecord
+	for cursor.Next(ctx) {
+		var record domain.ConsentRecord
+		if err := cursor.Decode(&record); err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+	}
+	return records, cursor.Err()
+}
@@ -0,0 +1,416 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/metrics"
+	"github.com/tahsin005/codercat-server/tracing"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// The instrumented repositories below wrap every call in a span and record
+// its latency and outcome in metrics.ObserveRepository, labelled by
+// repository and method. They wrap every storage backend; on Mongo the
+// commands a call issues appear as child spans.
+
+type instrumentedBlogRepository struct {
+	next BlogRepository
+}
+
+func NewInstrumentedBlogRepository(next BlogRepository) BlogRepository {
+	return &instrumentedBlogRepository{next: next}
+}
+
+func (r *instrumentedBlogRepository) Create(ctx context.Context, blog *domain.Blog) error {
+	ctx, span := tracing.Start(ctx, "BlogRepository.Create")
+	start := time.Now()
+	err := r.next.Create(ctx, blog)
+	metrics.ObserveRepository("blogs", "Create", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedBlogRepository) FindByID(ctx context.Context, id bson.ObjectID) (*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.FindByID")
+	start := time.Now()
+	v, err := r.next.FindByID(ctx, id)
+	metrics.ObserveRepository("blogs", "FindByID", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) Update(ctx context.Context, id bson.ObjectID, blog *domain.Blog) error {
+	ctx, span := tracing.Start(ctx, "BlogRepository.Update")
+	start := time.Now()
+	err := r.next.Update(ctx, id, blog)
+	metrics.ObserveRepository("blogs", "Update", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedBlogRepository) Delete(ctx context.Context, id bson.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "BlogRepository.Delete")
+	start := time.Now()
+	err := r.next.Delete(ctx, id)
+	metrics.ObserveRepository("blogs", "Delete", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedBlogRepository) FindAll(ctx context.Context) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.FindAll")
+	start := time.Now()
+	v, err := r.next.FindAll(ctx)
+	metrics.ObserveRepository("blogs", "FindAll", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) FindFeatured(ctx context.Context) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.FindFeatured")
+	start := time.Now()
+	v, err := r.next.FindFeatured(ctx)
+	metrics.ObserveRepository("blogs", "FindFeatured", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) FindRecent(ctx context.Context, limit int) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.FindRecent")
+	start := time.Now()
+	v, err := r.next.FindRecent(ctx, limit)
+	metrics.ObserveRepository("blogs", "FindRecent", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) FindByCategory(ctx context.Context, category string) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.FindByCategory")
+	start := time.Now()
+	v, err := r.next.FindByCategory(ctx, category)
+	metrics.ObserveRepository("blogs", "FindByCategory", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) Search(ctx context.Context, query string) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.Search")
+	start := time.Now()
+	v, err := r.next.Search(ctx, query)
+	metrics.ObserveRepository("blogs", "Search", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) FindRelated(ctx context.Context, blogID bson.ObjectID, limit int) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.FindRelated")
+	start := time.Now()
+	v, err := r.next.FindRelated(ctx, blogID, limit)
+	metrics.ObserveRepository("blogs", "FindRelated", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) GetCategories(ctx context.Context) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.GetCategories")
+	start := time.Now()
+	v, err := r.next.GetCategories(ctx)
+	metrics.ObserveRepository("blogs", "GetCategories", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) GetPopularCategories(ctx context.Context, limit int) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.GetPopularCategories")
+	start := time.Now()
+	v, err := r.next.GetPopularCategories(ctx, limit)
+	metrics.ObserveRepository("blogs", "GetPopularCategories", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) FindTrashed(ctx context.Context) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.FindTrashed")
+	start := time.Now()
+	v, err := r.next.FindTrashed(ctx)
+	metrics.ObserveRepository("blogs", "FindTrashed", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) Restore(ctx context.Context, id bson.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "BlogRepository.Restore")
+	start := time.Now()
+	err := r.next.Restore(ctx, id)
+	metrics.ObserveRepository("blogs", "Restore", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedBlogRepository) Purge(ctx context.Context, id bson.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "BlogRepository.Purge")
+	start := time.Now()
+	err := r.next.Purge(ctx, id)
+	metrics.ObserveRepository("blogs", "Purge", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedBlogRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.PurgeDeletedBefore")
+	start := time.Now()
+	v, err := r.next.PurgeDeletedBefore(ctx, cutoff)
+	metrics.ObserveRepository("blogs", "PurgeDeletedBefore", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) CountByStatus(ctx context.Context) (map[domain.BlogStatus]int64, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.CountByStatus")
+	start := time.Now()
+	v, err := r.next.CountByStatus(ctx)
+	metrics.ObserveRepository("blogs", "CountByStatus", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedBlogRepository) LastModified(ctx context.Context) (time.Time, error) {
+	ctx, span := tracing.Start(ctx, "BlogRepository.LastModified")
+	start := time.Now()
+	v, err := r.next.LastModified(ctx)
+	metrics.ObserveRepository("blogs", "LastModified", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+type instrumentedSubscriberRepository struct {
+	next SubscriberRepository
+}
+
+func NewInstrumentedSubscriberRepository(next SubscriberRepository) SubscriberRepository {
+	return &instrumentedSubscriberRepository{next: next}
+}
+
+func (r *instrumentedSubscriberRepository) CreateSubscriber(ctx context.Context, subscriber *domain.Subscriber) error {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.CreateSubscriber")
+	start := time.Now()
+	err := r.next.CreateSubscriber(ctx, subscriber)
+	metrics.ObserveRepository("subscribers", "CreateSubscriber", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedSubscriberRepository) GetAll(ctx context.Context) ([]*domain.Subscriber, error) {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.GetAll")
+	start := time.Now()
+	v, err := r.next.GetAll(ctx)
+	metrics.ObserveRepository("subscribers", "GetAll", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedSubscriberRepository) FindByStatus(ctx context.Context, status domain.SubscriberStatus) ([]*domain.Subscriber, error) {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.FindByStatus")
+	start := time.Now()
+	v, err := r.next.FindByStatus(ctx, status)
+	metrics.ObserveRepository("subscribers", "FindByStatus", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedSubscriberRepository) FindByEmailKey(ctx context.Context, emailKey string) (*domain.Subscriber, error) {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.FindByEmailKey")
+	start := time.Now()
+	v, err := r.next.FindByEmailKey(ctx, emailKey)
+	metrics.ObserveRepository("subscribers", "FindByEmailKey", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedSubscriberRepository) FindByConfirmToken(ctx context.Context, token string) (*domain.Subscriber, error) {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.FindByConfirmToken")
+	start := time.Now()
+	v, err := r.next.FindByConfirmToken(ctx, token)
+	metrics.ObserveRepository("subscribers", "FindByConfirmToken", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedSubscriberRepository) FindByManageToken(ctx context.Context, token string) (*domain.Subscriber, error) {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.FindByManageToken")
+	start := time.Now()
+	v, err := r.next.FindByManageToken(ctx, token)
+	metrics.ObserveRepository("subscribers", "FindByManageToken", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedSubscriberRepository) Confirm(ctx context.Context, id bson.ObjectID, at time.Time) error {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.Confirm")
+	start := time.Now()
+	err := r.next.Confirm(ctx, id, at)
+	metrics.ObserveRepository("subscribers", "Confirm", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedSubscriberRepository) FindByID(ctx context.Context, id bson.ObjectID) (*domain.Subscriber, error) {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.FindByID")
+	start := time.Now()
+	v, err := r.next.FindByID(ctx, id)
+	metrics.ObserveRepository("subscribers", "FindByID", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedSubscriberRepository) FindByEmailKeys(ctx context.Context, emailKeys []string) ([]*domain.Subscriber, error) {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.FindByEmailKeys")
+	start := time.Now()
+	v, err := r.next.FindByEmailKeys(ctx, emailKeys)
+	metrics.ObserveRepository("subscribers", "FindByEmailKeys", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedSubscriberRepository) List(ctx context.Context, filter domain.SubscriberFilter) ([]*domain.Subscriber, int64, error) {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.List")
+	start := time.Now()
+	v, n, err := r.next.List(ctx, filter)
+	metrics.ObserveRepository("subscribers", "List", start, err)
+	tracing.End(span, err)
+	return v, n, err
+}
+
+func (r *instrumentedSubscriberRepository) Delete(ctx context.Context, id bson.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.Delete")
+	start := time.Now()
+	err := r.next.Delete(ctx, id)
+	metrics.ObserveRepository("subscribers", "Delete", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedSubscriberRepository) CountByStatus(ctx context.Context) (map[domain.SubscriberStatus]int64, error) {
+	ctx, span := tracing.Start(ctx, "SubscriberRepository.CountByStatus")
+	start := time.Now()
+	v, err := r.next.CountByStatus(ctx)
+	metrics.ObserveRepository("subscribers", "CountByStatus", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+type instrumentedSendRepository struct {
+	next SendRepository
+}
+
+func NewInstrumentedSendRepository(next SendRepository) SendRepository {
+	return &instrumentedSendRepository{next: next}
+}
+
+func (r *instrumentedSendRepository) Create(ctx context.Context, event *domain.SendEvent) error {
+	ctx, span := tracing.Start(ctx, "SendRepository.Create")
+	start := time.Now()
+	err := r.next.Create(ctx, event)
+	metrics.ObserveRepository("sends", "Create", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedSendRepository) FindBySubscriber(ctx context.Context, subscriberID bson.ObjectID, limit int) ([]*domain.SendEvent, error) {
+	ctx, span := tracing.Start(ctx, "SendRepository.FindBySubscriber")
+	start := time.Now()
+	v, err := r.next.FindBySubscriber(ctx, subscriberID, limit)
+	metrics.ObserveRepository("sends", "FindBySubscriber", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedSendRepository) DeleteBySubscriber(ctx context.Context, subscriberID bson.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "SendRepository.DeleteBySubscriber")
+	start := time.Now()
+	err := r.next.DeleteBySubscriber(ctx, subscriberID)
+	metrics.ObserveRepository("sends", "DeleteBySubscriber", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+type instrumentedSuppressionRepository struct {
+	next SuppressionRepository
+}
+
+func NewInstrumentedSuppressionRepository(next SuppressionRepository) SuppressionRepository {
+	return &instrumentedSuppressionRepository{next: next}
+}
+
+func (r *instrumentedSuppressionRepository) Add(ctx context.Context, hash, reason string) error {
+	ctx, span := tracing.Start(ctx, "SuppressionRepository.Add")
+	start := time.Now()
+	err := r.next.Add(ctx, hash, reason)
+	metrics.ObserveRepository("suppressions", "Add", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedSuppressionRepository) Remove(ctx context.Context, hash string) error {
+	ctx, span := tracing.Start(ctx, "SuppressionRepository.Remove")
+	start := time.Now()
+	err := r.next.Remove(ctx, hash)
+	metrics.ObserveRepository("suppressions", "Remove", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedSuppressionRepository) FindExisting(ctx context.Context, hashes []string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "SuppressionRepository.FindExisting")
+	start := time.Now()
+	v, err := r.next.FindExisting(ctx, hashes)
+	metrics.ObserveRepository("suppressions", "FindExisting", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+type instrumentedConsentRepository struct {
+	next ConsentRepository
+}
+
+func NewInstrumentedConsentRepository(next ConsentRepository) ConsentRepository {
+	return &instrumentedConsentRepository{next: next}
+}
+
+func (r *instrumentedConsentRepository) Create(ctx context.Context, record *domain.ConsentRecord) error {
+	ctx, span := tracing.Start(ctx, "ConsentRepository.Create")
+	start := time.Now()
+	err := r.next.Create(ctx, record)
+	metrics.ObserveRepository("consents", "Create", start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *instrumentedConsentRepository) FindBySubscriber(ctx context.Context, subscriberID bson.ObjectID) ([]*domain.ConsentRecord, error) {
+	ctx, span := tracing.Start(ctx, "ConsentRepository.FindBySubscriber")
+	start := time.Now()
+	v, err := r.next.FindBySubscriber(ctx, subscriberID)
+	metrics.ObserveRepository("consents", "FindBySubscriber", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedConsentRepository) FindBySubscribers(ctx context.Context, subscriberIDs []bson.ObjectID) ([]*domain.ConsentRecord, error) {
+	ctx, span := tracing.Start(ctx, "ConsentRepository.FindBySubscribers")
+	start := time.Now()
+	v, err := r.next.FindBySubscribers(ctx, subscriberIDs)
+	metrics.ObserveRepository("consents", "FindBySubscribers", start, err)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (r *instrumentedConsentRepository) DeleteBySubscriber(ctx context.Context, subscriberID bson.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "ConsentRepository.DeleteBySubscriber")
+	start := time.Now()
+	err := r.next.DeleteBySubscriber(ctx, subscriberID)
+	metrics.ObserveRepository("consents", "DeleteBySubscriber", start, err)
+	tracing.End(span, err)
+	return err
+}
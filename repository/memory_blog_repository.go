@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type memoryBlogRepository struct {
+	mu sync.RWMutex
+	// blogs are kept in insertion order, which stands in for a
+	// collection's natural order.
+	blogs []*domain.Blog
+}
+
+// NewMemoryBlogRepository returns a BlogRepository that keeps posts in
+// memory, for tests and for running without MongoDB. It follows the
+// semantics of the Mongo repository, with Search approximating the text
+// index by matching whole words.
+func NewMemoryBlogRepository() BlogRepository {
+	return &memoryBlogRepository{}
+}
+
+func (r *memoryBlogRepository) Create(_ context.Context, blog *domain.Blog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	blog.ID = bson.NewObjectID()
+	blog.CreatedAt = now
+	blog.UpdatedAt = now
+	blog.DeletedAt = nil
+	r.blogs = append(r.blogs, cloneBlog(blog))
+	return nil
+}
+
+func (r *memoryBlogRepository) FindByID(_ context.Context, id bson.ObjectID) (*domain.Blog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i := r.index(id, false)
+	if i < 0 {
+		return nil, domain.ErrNotFound
+	}
+	return cloneBlog(r.blogs[i]), nil
+}
+
+// Update replaces a live post. As with the Mongo $set, a nil PublishedAt
+// leaves the stored publication date alone.
+func (r *memoryBlogRepository) Update(_ context.Context, id bson.ObjectID, blog *domain.Blog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id, false)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	blog.ID = id
+	blog.UpdatedAt = time.Now().UTC()
+	blog.DeletedAt = nil
+	stored := cloneBlog(blog)
+	if stored.PublishedAt == nil {
+		stored.PublishedAt = r.blogs[i].PublishedAt
+	}
+	r.blogs[i] = stored
+	return nil
+}
+
+func (r *memoryBlogRepository) Delete(_ context.Context, id bson.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id, false)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	now := time.Now().UTC()
+	r.blogs[i].DeletedAt = &now
+	return nil
+}
+
+func (r *memoryBlogRepository) FindTrashed(_ context.Context) ([]*domain.Blog, error) {
+	blogs := r.filter(func(b *domain.Blog) bool { return b.DeletedAt != nil })
+	slices.SortStableFunc(blogs, func(a, b *domain.Blog) int {
+		return b.DeletedAt.Compare(*a.DeletedAt)
+	})
+	return blogs, nil
+}
+
+func (r *memoryBlogRepository) Restore(_ context.Context, id bson.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id, true)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	r.blogs[i].DeletedAt = nil
+	r.blogs[i].UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (r *memoryBlogRepository) Purge(_ context.Context, id bson.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id, true)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	r.blogs = slices.Delete(r.blogs, i, i+1)
+	return nil
+}
+
+func (r *memoryBlogRepository) PurgeDeletedBefore(_ context.Context, cutoff time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before := len(r.blogs)
+	r.blogs = slices.DeleteFunc(r.blogs, func(b *domain.Blog) bool {
+		return b.DeletedAt != nil && b.DeletedAt.Before(cutoff)
+	})
+	return int64(before - len(r.blogs)), nil
+}
+
+func (r *memoryBlogRepository) FindAll(_ context.Context) ([]*domain.Blog, error) {
+	return r.filter(live), nil
+}
+
+func (r *memoryBlogRepository) FindFeatured(_ context.Context) ([]*domain.Blog, error) {
+	return r.filter(func(b *domain.Blog) bool { return live(b) && b.Featured }), nil
+}
+
+// FindRecent sorts posts without a publication date last, as Mongo sorts
+// missing fields below any date.
+func (r *memoryBlogRepository) FindRecent(_ context.Context, limit int) ([]*domain.Blog, error) {
+	blogs := r.filter(live)
+	slices.SortStableFunc(blogs, func(a, b *domain.Blog) int {
+		switch {
+		case a.PublishedAt == nil && b.PublishedAt == nil:
+			return 0
+		case a.PublishedAt == nil:
+			return 1
+		case b.PublishedAt == nil:
+			return -1
+		}
+		return b.PublishedAt.Compare(*a.PublishedAt)
+	})
+	return truncate(blogs, limit), nil
+}
+
+func (r *memoryBlogRepository) FindByCategory(_ context.Context, category string) ([]*domain.Blog, error) {
+	return r.filter(func(b *domain.Blog) bool {
+		return live(b) && (category == "All" || b.Category == category)
+	}), nil
+}
+
+// Search scores posts by how often the query's words appear in them,
+// weighting fields as the text index does. Posts matching no word are
+// left out. An empty query returns every post.
+func (r *memoryBlogRepository) Search(ctx context.Context, query string) ([]*domain.Blog, error) {
+	terms := words(query)
+	if len(terms) == 0 {
+		return r.FindAll(ctx)
+	}
+	type scored struct {
+		blog  *domain.Blog
+		score int
+	}
+	var results []scored
+	for _, b := range r.filter(live) {
+		score := 10*matches(terms, b.Title) +
+			5*matches(terms, strings.Join(b.Tags, " ")) +
+			3*matches(terms, b.Excerpt) +
+			matches(terms, b.Content)
+		if score > 0 {
+			results = append(results, scored{b, score})
+		}
+	}
+	slices.SortStableFunc(results, func(a, b scored) int { return b.score - a.score })
+	blogs := make([]*domain.Blog, len(results))
+	for i, res := range results {
+		blogs[i] = res.blog
+	}
+	return blogs, nil
+}
+
+func (r *memoryBlogRepository) FindRelated(ctx context.Context, blogID bson.ObjectID, limit int) ([]*domain.Blog, error) {
+	current, err := r.FindByID(ctx, blogID)
+	if err != nil {
+		return nil, err
+	}
+	blogs := r.filter(func(b *domain.Blog) bool {
+		if !live(b) || b.ID == blogID {
+			return false
+		}
+		if b.Category == current.Category {
+			return true
+		}
+		return slices.ContainsFunc(b.Tags, func(tag string) bool {
+			return slices.Contains(current.Tags, tag)
+		})
+	})
+	return truncate(blogs, limit), nil
+}
+
+func (r *memoryBlogRepository) GetCategories(_ context.Context) ([]string, error) {
+	var categories []string
+	for _, b := range r.filter(live) {
+		if !slices.Contains(categories, b.Category) {
+			categories = append(categories, b.Category)
+		}
+	}
+	slices.Sort(categories)
+	return append([]string{"All"}, categories...), nil
+}
+
+// GetPopularCategories orders categories by post count, breaking ties by
+// name so that results are stable.
+func (r *memoryBlogRepository) GetPopularCategories(_ context.Context, limit int) ([]string, error) {
+	counts := make(map[string]int)
+	for _, b := range r.filter(live) {
+		counts[b.Category]++
+	}
+	categories := make([]string, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	slices.SortFunc(categories, func(a, b string) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return truncate(categories, limit), nil
+}
+
+func (r *memoryBlogRepository) CountByStatus(_ context.Context) (map[domain.BlogStatus]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := time.Now().UTC()
+	counts := map[domain.BlogStatus]int64{
+		domain.BlogPublished: 0,
+		domain.BlogScheduled: 0,
+		domain.BlogTrashed:   0,
+	}
+	for _, b := range r.blogs {
+		switch {
+		case b.DeletedAt != nil:
+			counts[domain.BlogTrashed]++
+		case b.PublishedAt != nil && b.PublishedAt.After(now):
+			counts[domain.BlogScheduled]++
+		default:
+			counts[domain.BlogPublished]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *memoryBlogRepository) LastModified(_ context.Context) (time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := time.Now().UTC()
+	var t time.Time
+	for _, b := range r.blogs {
+		published := b.PublishedAt
+		if published != nil && published.After(now) {
+			published = nil
+		}
+		t = latest(&t, &b.UpdatedAt, b.DeletedAt, published)
+	}
+	return t, nil
+}
+
+// index returns the position of the post with the given id that is, or is
+// not, in the trash, or -1.
+func (r *memoryBlogRepository) index(id bson.ObjectID, trashed bool) int {
+	return slices.IndexFunc(r.blogs, func(b *domain.Blog) bool {
+		return b.ID == id && (b.DeletedAt != nil) == trashed
+	})
+}
+
+// filter returns copies of the posts matching keep, in insertion order.
+func (r *memoryBlogRepository) filter(keep func(*domain.Blog) bool) []*domain.Blog {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var blogs []*domain.Blog
+	for _, b := range r.blogs {
+		if keep(b) {
+			blogs = append(blogs, cloneBlog(b))
+		}
+	}
+	return blogs
+}
+
+func live(b *domain.Blog) bool {
+	return b.DeletedAt == nil
+}
+
+func cloneBlog(b *domain.Blog) *domain.Blog {
+	c := *b
+	c.Tags = slices.Clone(b.Tags)
+	c.TOC = slices.Clone(b.TOC)
+	c.PublishedAt = cloneTime(b.PublishedAt)
+	c.DeletedAt = cloneTime(b.DeletedAt)
+	return &c
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// truncate returns the first limit items of s. A limit of zero or less
+// means no limit, as it does for a Mongo find.
+func truncate[T any](s []T, limit int) []T {
+	if limit > 0 && len(s) > limit {
+		return s[:limit]
+	}
+	return s
+}
+
+// words splits s into lower-case words.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matches counts the words of text that are one of terms.
+func matches(terms []string, text string) int {
+	n := 0
+	for _, w := range words(text) {
+		if slices.Contains(terms, w) {
+			n++
+		}
+	}
+	return n
+}
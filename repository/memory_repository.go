@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type memorySendRepository struct {
+	mu     sync.RWMutex
+	events []*domain.SendEvent
+}
+
+// NewMemorySendRepository returns a SendRepository that keeps the send
+// history in memory.
+func NewMemorySendRepository() SendRepository {
+	return &memorySendRepository{}
+}
+
+func (r *memorySendRepository) Create(_ context.Context, event *domain.SendEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event.ID = bson.NewObjectID()
+	c := *event
+	c.BlogID = cloneID(event.BlogID)
+	r.events = append(r.events, &c)
+	return nil
+}
+
+func (r *memorySendRepository) FindBySubscriber(_ context.Context, subscriberID bson.ObjectID, limit int) ([]*domain.SendEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var events []*domain.SendEvent
+	for _, e := range r.events {
+		if e.SubscriberID == subscriberID {
+			c := *e
+			c.BlogID = cloneID(e.BlogID)
+			events = append(events, &c)
+		}
+	}
+	slices.SortStableFunc(events, func(a, b *domain.SendEvent) int { return b.SentAt.Compare(a.SentAt) })
+	return truncate(events, limit), nil
+}
+
+func (r *memorySendRepository) DeleteBySubscriber(_ context.Context, subscriberID bson.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = slices.DeleteFunc(r.events, func(e *domain.SendEvent) bool { return e.SubscriberID == subscriberID })
+	return nil
+}
+
+type memorySuppressionRepository struct {
+	mu           sync.RWMutex
+	suppressions map[string]domain.Suppression
+}
+
+// NewMemorySuppressionRepository returns a SuppressionRepository that keeps
+// suppressed hashes in memory.
+func NewMemorySuppressionRepository() SuppressionRepository {
+	return &memorySuppressionRepository{suppressions: make(map[string]domain.Suppression)}
+}
+
+func (r *memorySuppressionRepository) Add(_ context.Context, hash, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.suppressions[hash]; !ok {
+		r.suppressions[hash] = domain.Suppression{Hash: hash, Reason: reason, CreatedAt: time.Now().UTC()}
+	}
+	return nil
+}
+
+func (r *memorySuppressionRepository) Remove(_ context.Context, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.suppressions, hash)
+	return nil
+}
+
+func (r *memorySuppressionRepository) FindExisting(_ context.Context, hashes []string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var found []string
+	for _, hash := range hashes {
+		if _, ok := r.suppressions[hash]; ok && !slices.Contains(found, hash) {
+			found = append(found, hash)
+		}
+	}
+	return found, nil
+}
+
+type memoryConsentRepository struct {
+	mu      sync.RWMutex
+	records []*domain.ConsentRecord
+}
+
+// NewMemoryConsentRepository returns a ConsentRepository that keeps the
+// consent log in memory.
+func NewMemoryConsentRepository() ConsentRepository {
+	return &memoryConsentRepository{}
+}
+
+func (r *memoryConsentRepository) Create(_ context.Context, record *domain.ConsentRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record.ID = bson.NewObjectID()
+	c := *record
+	r.records = append(r.records, &c)
+	return nil
+}
+
+func (r *memoryConsentRepository) FindBySubscriber(ctx context.Context, subscriberID bson.ObjectID) ([]*domain.ConsentRecord, error) {
+	return r.FindBySubscribers(ctx, []bson.ObjectID{subscriberID})
+}
+
+// FindBySubscribers returns matching records oldest first, the order they
+// were logged.
+func (r *memoryConsentRepository) FindBySubscribers(_ context.Context, subscriberIDs []bson.ObjectID) ([]*domain.ConsentRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var records []*domain.ConsentRecord
+	for _, record := range r.records {
+		if slices.Contains(subscriberIDs, record.SubscriberID) {
+			c := *record
+			records = append(records, &c)
+		}
+	}
+	slices.SortStableFunc(records, func(a, b *domain.ConsentRecord) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return records, nil
+}
+
+func (r *memoryConsentRepository) DeleteBySubscriber(_ context.Context, subscriberID bson.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = slices.DeleteFunc(r.records, func(record *domain.ConsentRecord) bool { return record.SubscriberID == subscriberID })
+	return nil
+}
+
+func cloneID(id *bson.ObjectID) *bson.ObjectID {
+	if id == nil {
+		return nil
+	}
+	c := *id
+	return &c
+}
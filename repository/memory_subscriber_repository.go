@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type memorySubscriberRepository struct {
+	mu          sync.RWMutex
+	subscribers []*domain.Subscriber
+}
+
+// NewMemorySubscriberRepository returns a SubscriberRepository that keeps
+// subscribers in memory, enforcing the same unique email and manage token
+// constraints as the Mongo indexes.
+func NewMemorySubscriberRepository() SubscriberRepository {
+	return &memorySubscriberRepository{}
+}
+
+func (r *memorySubscriberRepository) CreateSubscriber(_ context.Context, subscriber *domain.Subscriber) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.subscribers {
+		if s.EmailKey == subscriber.EmailKey || (subscriber.ManageToken != "" && s.ManageToken == subscriber.ManageToken) {
+			return domain.ErrConflict
+		}
+	}
+	subscriber.ID = bson.NewObjectID()
+	subscriber.CreatedAt = time.Now().UTC()
+	r.subscribers = append(r.subscribers, cloneSubscriber(subscriber))
+	return nil
+}
+
+func (r *memorySubscriberRepository) GetAll(_ context.Context) ([]*domain.Subscriber, error) {
+	return r.find(func(*domain.Subscriber) bool { return true }), nil
+}
+
+func (r *memorySubscriberRepository) FindByID(_ context.Context, id bson.ObjectID) (*domain.Subscriber, error) {
+	return r.findOne(func(s *domain.Subscriber) bool { return s.ID == id })
+}
+
+func (r *memorySubscriberRepository) FindByEmailKeys(_ context.Context, emailKeys []string) ([]*domain.Subscriber, error) {
+	return r.find(func(s *domain.Subscriber) bool { return slices.Contains(emailKeys, s.EmailKey) }), nil
+}
+
+func (r *memorySubscriberRepository) List(_ context.Context, filter domain.SubscriberFilter) ([]*domain.Subscriber, int64, error) {
+	search := strings.ToLower(filter.Search)
+	subscribers := r.find(func(s *domain.Subscriber) bool {
+		return (filter.Status == "" || s.Status == filter.Status) &&
+			strings.Contains(strings.ToLower(s.Email), search)
+	})
+	slices.SortStableFunc(subscribers, func(a, b *domain.Subscriber) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(b.ID[:], a.ID[:])
+	})
+	total := int64(len(subscribers))
+	subscribers = subscribers[min(filter.Skip, len(subscribers)):]
+	return truncate(subscribers, filter.Limit), total, nil
+}
+
+func (r *memorySubscriberRepository) Delete(_ context.Context, id bson.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := slices.IndexFunc(r.subscribers, func(s *domain.Subscriber) bool { return s.ID == id })
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	r.subscribers = slices.Delete(r.subscribers, i, i+1)
+	return nil
+}
+
+func (r *memorySubscriberRepository) FindByStatus(_ context.Context, status domain.SubscriberStatus) ([]*domain.Subscriber, error) {
+	return r.find(func(s *domain.Subscriber) bool { return s.Status == status }), nil
+}
+
+func (r *memorySubscriberRepository) FindByEmailKey(_ context.Context, emailKey string) (*domain.Subscriber, error) {
+	return r.findOne(func(s *domain.Subscriber) bool { return s.EmailKey == emailKey })
+}
+
+func (r *memorySubscriberRepository) FindByConfirmToken(_ context.Context, token string) (*domain.Subscriber, error) {
+	return r.findOne(func(s *domain.Subscriber) bool { return token != "" && s.ConfirmToken == token })
+}
+
+func (r *memorySubscriberRepository) FindByManageToken(_ context.Context, token string) (*domain.Subscriber, error) {
+	return r.findOne(func(s *domain.Subscriber) bool { return token != "" && s.ManageToken == token })
+}
+
+func (r *memorySubscriberRepository) Confirm(_ context.Context, id bson.ObjectID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := slices.IndexFunc(r.subscribers, func(s *domain.Subscriber) bool { return s.ID == id })
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	s := r.subscribers[i]
+	s.Status = domain.SubscriberConfirmed
+	s.ConfirmedAt = &at
+	s.ConfirmToken = ""
+	return nil
+}
+
+func (r *memorySubscriberRepository) CountByStatus(_ context.Context) (map[domain.SubscriberStatus]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	counts := map[domain.SubscriberStatus]int64{
+		domain.SubscriberPending:   0,
+		domain.SubscriberConfirmed: 0,
+	}
+	for _, s := range r.subscribers {
+		counts[s.Status]++
+	}
+	return counts, nil
+}
+
+func (r *memorySubscriberRepository) findOne(match func(*domain.Subscriber) bool) (*domain.Subscriber, error) {
+	subscribers := r.find(match)
+	if len(subscribers) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	return subscribers[0], nil
+}
+
+// find returns copies of the subscribers matching match, in insertion
+// order.
+func (r *memorySubscriberRepository) find(match func(*domain.Subscriber) bool) []*domain.Subscriber {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var subscribers []*domain.Subscriber
+	for _, s := range r.subscribers {
+		if match(s) {
+			subscribers = append(subscribers, cloneSubscriber(s))
+		}
+	}
+	return subscribers
+}
+
+func cloneSubscriber(s *domain.Subscriber) *domain.Subscriber {
+	c := *s
+	c.ConfirmedAt = cloneTime(s.ConfirmedAt)
+	return &c
+}
@@ -0,0 +1,101 @@
+package repository_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestSendRepository(t *testing.T) {
+	run(t, backends(repository.NewMemorySendRepository, repository.NewSendRepository, repository.NewSQLSendRepository), func(t *testing.T, newRepo func(*testing.T) repository.SendRepository) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		sub, other := bson.NewObjectID(), bson.NewObjectID()
+		base := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+		for i, subject := range []string{"first", "second", "third"} {
+			must(t, repo.Create(ctx, &domain.SendEvent{SubscriberID: sub, Subject: subject, Status: domain.SendSent, SentAt: base.Add(time.Duration(i) * time.Hour)}))
+		}
+		must(t, repo.Create(ctx, &domain.SendEvent{SubscriberID: other, Subject: "other", SentAt: base}))
+
+		events, err := repo.FindBySubscriber(ctx, sub, 2)
+		must(t, err)
+		var subjects []string
+		for _, e := range events {
+			subjects = append(subjects, e.Subject)
+		}
+		if !slices.Equal(subjects, []string{"third", "second"}) {
+			t.Errorf("FindBySubscriber(limit 2) = %v, want newest first", subjects)
+		}
+
+		must(t, repo.DeleteBySubscriber(ctx, sub))
+		events, err = repo.FindBySubscriber(ctx, sub, 0)
+		must(t, err)
+		if len(events) != 0 {
+			t.Errorf("FindBySubscriber after DeleteBySubscriber returned %d events", len(events))
+		}
+		events, err = repo.FindBySubscriber(ctx, other, 0)
+		must(t, err)
+		if len(events) != 1 {
+			t.Errorf("DeleteBySubscriber removed another subscriber's sends")
+		}
+	})
+}
+
+func TestSuppressionRepository(t *testing.T) {
+	run(t, backends(repository.NewMemorySuppressionRepository, repository.NewSuppressionRepository, repository.NewSQLSuppressionRepository), func(t *testing.T, newRepo func(*testing.T) repository.SuppressionRepository) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		must(t, repo.Add(ctx, "a", "erased"))
+		must(t, repo.Add(ctx, "a", "erased again"))
+		must(t, repo.Add(ctx, "b", "erased"))
+
+		found, err := repo.FindExisting(ctx, []string{"a", "b", "c"})
+		must(t, err)
+		slices.Sort(found)
+		if !slices.Equal(found, []string{"a", "b"}) {
+			t.Errorf("FindExisting = %v", found)
+		}
+
+		must(t, repo.Remove(ctx, "a"))
+		found, err = repo.FindExisting(ctx, []string{"a", "b"})
+		must(t, err)
+		if !slices.Equal(found, []string{"b"}) {
+			t.Errorf("FindExisting after Remove = %v", found)
+		}
+	})
+}
+
+func TestConsentRepository(t *testing.T) {
+	run(t, backends(repository.NewMemoryConsentRepository, repository.NewConsentRepository, repository.NewSQLConsentRepository), func(t *testing.T, newRepo func(*testing.T) repository.ConsentRepository) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		sub, other := bson.NewObjectID(), bson.NewObjectID()
+		base := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+		must(t, repo.Create(ctx, &domain.ConsentRecord{SubscriberID: sub, Action: domain.ConsentConfirm, CreatedAt: base.Add(time.Hour)}))
+		must(t, repo.Create(ctx, &domain.ConsentRecord{SubscriberID: sub, Action: domain.ConsentSubscribe, CreatedAt: base}))
+		must(t, repo.Create(ctx, &domain.ConsentRecord{SubscriberID: other, Action: domain.ConsentImport, CreatedAt: base}))
+
+		records, err := repo.FindBySubscriber(ctx, sub)
+		must(t, err)
+		if len(records) != 2 || records[0].Action != domain.ConsentSubscribe || records[1].Action != domain.ConsentConfirm {
+			t.Errorf("FindBySubscriber = %+v, want oldest first", records)
+		}
+		records, err = repo.FindBySubscribers(ctx, []bson.ObjectID{sub, other})
+		must(t, err)
+		if len(records) != 3 {
+			t.Errorf("FindBySubscribers returned %d records, want 3", len(records))
+		}
+
+		must(t, repo.DeleteBySubscriber(ctx, sub))
+		records, err = repo.FindBySubscribers(ctx, []bson.ObjectID{sub, other})
+		must(t, err)
+		if len(records) != 1 || records[0].SubscriberID != other {
+			t.Errorf("after DeleteBySubscriber: %+v", records)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tahsin005/codercat-server/config"
+	"github.com/tahsin005/codercat-server/database"
+	"github.com/tahsin005/codercat-server/migration"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// backend builds an empty repository for a single test.
+type backend[R any] struct {
+	name string
+	skip string
+	new  func(t *testing.T) R
+}
+
+// backends returns the in-memory and SQLite backends and, when
+// MONGO_TEST_URI or POSTGRES_TEST_URL point at a server, Mongo and
+// Postgres backends. Every test gets a throwaway database.
+func backends[R any](memory func() R, mongo func(*database.Database, *config.Config) R, sqlRepo func(*database.SQLDatabase) R) []backend[R] {
+	return []backend[R]{
+		{"memory", "", func(*testing.T) R { return memory() }},
+		{"mongo", mongoSkip(), func(t *testing.T) R {
+			db, cfg := mongoTestDatabase(t)
+			return mongo(db, cfg)
+		}},
+		{"sqlite", "", func(t *testing.T) R {
+			return sqlRepo(sqlTestDatabase(t, "sqlite", filepath.Join(t.TempDir(), "test.db")))
+		}},
+		{"postgres", postgresSkip(), func(t *testing.T) R {
+			return sqlRepo(sqlTestDatabase(t, "postgres", postgresTestSchema(t)))
+		}},
+	}
+}
+
+// run runs contract against every backend.
+func run[R any](t *testing.T, backends []backend[R], contract func(t *testing.T, newRepo func(t *testing.T) R)) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			if b.skip != "" {
+				t.Skip(b.skip)
+			}
+			contract(t, b.new)
+		})
+	}
+}
+
+func mongoSkip() string {
+	if os.Getenv("MONGO_TEST_URI") == "" {
+		return "MONGO_TEST_URI not set"
+	}
+	return ""
+}
+
+// mongoTestDatabase connects to MONGO_TEST_URI and returns a new database
+// with every migration applied, dropped again when the test ends.
+func mongoTestDatabase(t *testing.T) (*database.Database, *config.Config) {
+	t.Helper()
+	cfg := &config.Config{
+		MongoURI:                  os.Getenv("MONGO_TEST_URI"),
+		MongoDBName:               "codercat_test_" + bson.NewObjectID().Hex(),
+		MongoCollNameBlogs:        "blogs",
+		MongoCollNameSubscribers:  "subscribers",
+		MongoCollNameSends:        "sends",
+		MongoCollNameSuppressions: "suppressions",
+		MongoCollNameConsents:     "consents",
+		MongoCollNameRateLimits:   "rate_limits",
+	}
+	db, err := database.NewDatabase(cfg)
+	if err != nil {
+		t.Fatalf("connect to MongoDB: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := db.DB.Drop(ctx); err != nil {
+			t.Errorf("drop test database: %v", err)
+		}
+		db.Disconnect(ctx)
+	})
+	if _, err := migration.NewRunner(db.DB, migration.All(cfg)).Up(context.Background()); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db, cfg
+}
+
+func postgresSkip() string {
+	if os.Getenv("POSTGRES_TEST_URL") == "" {
+		return "POSTGRES_TEST_URL not set"
+	}
+	return ""
+}
+
+// sqlTestDatabase opens a SQL database with every migration applied.
+func sqlTestDatabase(t *testing.T, driver, url string) *database.SQLDatabase {
+	t.Helper()
+	db, err := database.NewSQLDatabase(&config.Config{DatabaseDriver: driver, DatabaseURL: url})
+	if err != nil {
+		t.Fatalf("open %s database: %v", driver, err)
+	}
+	t.Cleanup(db.Close)
+	if _, err := migration.NewSQLRunner(db).Up(context.Background()); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
+
+// postgresTestSchema creates a schema on POSTGRES_TEST_URL, dropped again
+// when the test ends, and returns a URL that uses it.
+func postgresTestSchema(t *testing.T) string {
+	t.Helper()
+	base := os.Getenv("POSTGRES_TEST_URL")
+	admin, err := sql.Open("pgx", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := "codercat_test_" + bson.NewObjectID().Hex()
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("drop test schema: %v", err)
+		}
+		admin.Close()
+	})
+
+	u, err := url.Parse(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func assertErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("err = %v, want %v", err, want)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// date returns midnight UTC on the given day, which survives Mongo's
+// millisecond precision unchanged.
+func date(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &d
+}
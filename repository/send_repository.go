@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/tahsin005/codercat-server/config"
+	"github.com/tahsin005/codercat-server/database"
+	"github.com/tahsin005/codercat-server/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type SendRepository interface {
+	Create(ctx context.Context, event *domain.SendEvent) error
+	FindBySubscriber(ctx context.Context, subscriberID bson.ObjectID, limit int) ([]*domain.SendEvent, error)
+	DeleteBySubscriber(ctx context.Context, subscriberID bson.ObjectID) error
+}
+
+type sendRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSendRepository(db *database.Database, cfg *config.Config) SendRepository {
+	return &sendRepository{
+		collection: db.DB.Collection(cfg.MongoCollNameSends),
+	}
+}
+
+func (r *sendRepository) Create(ctx context.Context, event *domain.SendEvent) error {
+	event.ID = bson.NewObjectID()
+	_, err := r.collection.InsertOne(ctx, event)
+	return err
+}
+
+// FindBySubscriber returns a subscriber's sends, newest first. A limit of
+// zero returns all of them.
+func (r *sendRepository) FindBySubscriber(ctx context.Context, subscriberID bson.ObjectID, limit int) ([]*domain.SendEvent, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "sentAt", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := r.collection.Find(ctx, bson.D{{Key: "subscriberId", Value: subscriberID}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []*domain.SendEvent
+	for cursor.Next(ctx) {
+		var event domain.SendEvent
+		if err := cursor.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, cursor.Err()
+}
+
+func (r *sendRepository) DeleteBySubscriber(ctx context.Context, subscriberID bson.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.D{{Key: "subscriberId", Value: subscriberID}})
+	return err
+}
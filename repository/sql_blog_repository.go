@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tahsin005/codercat-server/database"
+	"github.com/tahsin005/codercat-server/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type sqlBlogRepository struct {
+	db *database.SQLDatabase
+}
+
+// NewSQLBlogRepository stores posts in the blogs table, with tags in the
+// tags and blog_tags join tables and a full-text index kept alongside:
+// FTS5 on SQLite and a weighted tsvector on Postgres.
+func NewSQLBlogRepository(db *database.SQLDatabase) BlogRepository {
+	return &sqlBlogRepository{db: db}
+}
+
+const blogColumns = "id, title, excerpt, content, content_html, toc, author, author_image, read_time, word_count, " +
+	"category, image, featured, excerpt_auto, read_time_auto, created_at, updated_at, published_at, deleted_at"
+
+// Posts without an explicit order come back in creation order, as they do
+// from a Mongo collection.
+const blogOrder = " ORDER BY created_at, id"
+
+func (r *sqlBlogRepository) Create(ctx context.Context, blog *domain.Blog) error {
+	now := time.Now().UTC()
+	blog.ID = bson.NewObjectID()
+	blog.CreatedAt = now
+	blog.UpdatedAt = now
+	blog.DeletedAt = nil
+	toc, err := json.Marshal(blog.TOC)
+	if err != nil {
+		return err
+	}
+	return r.db.InTx(ctx, func(tx *database.SQLTx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO blogs ("+blogColumns+") VALUES ("+placeholders(19)+")",
+			blog.ID.Hex(), blog.Title, blog.Excerpt, blog.Content, blog.ContentHTML, string(toc),
+			blog.Author, blog.AuthorImage, blog.ReadTime, blog.WordCount, blog.Category, blog.Image,
+			blog.Featured, blog.ExcerptAuto, blog.ReadTimeAuto, sqlTime(blog.CreatedAt), sqlTime(blog.UpdatedAt),
+			sqlNullTime(blog.PublishedAt), nil)
+		if err != nil {
+			return err
+		}
+		return r.index(ctx, tx, blog)
+	})
+}
+
+func (r *sqlBlogRepository) FindByID(ctx context.Context, id bson.ObjectID) (*domain.Blog, error) {
+	return r.findOne(ctx, "WHERE id = ? AND deleted_at IS NULL", id.Hex())
+}
+
+// Update replaces a live post. As with Mongo's $set, a nil PublishedAt
+// keeps the stored publication date.
+func (r *sqlBlogRepository) Update(ctx context.Context, id bson.ObjectID, blog *domain.Blog) error {
+	blog.ID = id
+	blog.UpdatedAt = time.Now().UTC()
+	blog.DeletedAt = nil
+	toc, err := json.Marshal(blog.TOC)
+	if err != nil {
+		return err
+	}
+	return r.db.InTx(ctx, func(tx *database.SQLTx) error {
+		err := sqlAffected(tx.ExecContext(ctx, `UPDATE blogs SET title = ?, excerpt = ?, content = ?, content_html = ?, toc = ?,
+			author = ?, author_image = ?, read_time = ?, word_count = ?, category = ?, image = ?, featured = ?,
+			excerpt_auto = ?, read_time_auto = ?, created_at = ?, updated_at = ?, published_at = COALESCE(?, published_at)
+			WHERE id = ? AND deleted_at IS NULL`,
+			blog.Title, blog.Excerpt, blog.Content, blog.ContentHTML, string(toc),
+			blog.Author, blog.AuthorImage, blog.ReadTime, blog.WordCount, blog.Category, blog.Image, blog.Featured,
+			blog.ExcerptAuto, blog.ReadTimeAuto, sqlTime(blog.CreatedAt), sqlTime(blog.UpdatedAt), sqlNullTime(blog.PublishedAt),
+			id.Hex()))
+		if err != nil {
+			return err
+		}
+		if err := r.index(ctx, tx, blog); err != nil {
+			return err
+		}
+		return deleteUnusedTags(ctx, tx)
+	})
+}
+
+// Delete moves a post to the trash by stamping deleted_at. Trashed posts
+// are invisible to every other query until restored or purged.
+func (r *sqlBlogRepository) Delete(ctx context.Context, id bson.ObjectID) error {
+	return sqlAffected(r.db.ExecContext(ctx, "UPDATE blogs SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		sqlTime(time.Now()), id.Hex()))
+}
+
+func (r *sqlBlogRepository) FindTrashed(ctx context.Context) ([]*domain.Blog, error) {
+	return r.find(ctx, "WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+}
+
+func (r *sqlBlogRepository) Restore(ctx context.Context, id bson.ObjectID) error {
+	return sqlAffected(r.db.ExecContext(ctx, "UPDATE blogs SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NOT NULL",
+		sqlTime(time.Now()), id.Hex()))
+}
+
+// Purge permanently removes a post. Only trashed posts can be purged.
+func (r *sqlBlogRepository) Purge(ctx context.Context, id bson.ObjectID) error {
+	return r.db.InTx(ctx, func(tx *database.SQLTx) error {
+		err := sqlAffected(tx.ExecContext(ctx, "DELETE FROM blogs WHERE id = ? AND deleted_at IS NOT NULL", id.Hex()))
+		if err != nil {
+			return err
+		}
+		return deleteUnusedTags(ctx, tx)
+	})
+}
+
+func (r *sqlBlogRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	var n int64
+	err := r.db.InTx(ctx, func(tx *database.SQLTx) error {
+		res, err := tx.ExecContext(ctx, "DELETE FROM blogs WHERE deleted_at < ?", sqlTime(cutoff))
+		if err != nil {
+			return err
+		}
+		if n, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		return deleteUnusedTags(ctx, tx)
+	})
+	return n, err
+}
+
+func (r *sqlBlogRepository) FindAll(ctx context.Context) ([]*domain.Blog, error) {
+	return r.find(ctx, "WHERE deleted_at IS NULL"+blogOrder)
+}
+
+func (r *sqlBlogRepository) FindFeatured(ctx context.Context) ([]*domain.Blog, error) {
+	return r.find(ctx, "WHERE featured AND deleted_at IS NULL"+blogOrder)
+}
+
+// FindRecent returns the most recently published posts, with unpublished
+// ones last as Mongo sorts them.
+func (r *sqlBlogRepository) FindRecent(ctx context.Context, limit int) ([]*domain.Blog, error) {
+	page, args := sqlPage(r.db.Dialect, limit, 0)
+	return r.find(ctx, "WHERE deleted_at IS NULL ORDER BY published_at IS NULL, published_at DESC"+page, args...)
+}
+
+func (r *sqlBlogRepository) FindByCategory(ctx context.Context, category string) ([]*domain.Blog, error) {
+	if category == "All" {
+		return r.FindAll(ctx)
+	}
+	return r.find(ctx, "WHERE category = ? AND deleted_at IS NULL"+blogOrder, category)
+}
+
+// Search matches posts against the full-text index, best matches first,
+// weighting title, tags, excerpt and content as the Mongo text index does.
+// Any of the query's words may match. An empty query returns every post.
+func (r *sqlBlogRepository) Search(ctx context.Context, query string) ([]*domain.Blog, error) {
+	terms := words(query)
+	if len(terms) == 0 {
+		return r.FindAll(ctx)
+	}
+	if r.db.Dialect == database.Postgres {
+		return r.find(ctx, `, to_tsquery('english', ?) AS query
+			WHERE search @@ query AND deleted_at IS NULL
+			ORDER BY ts_rank('{0.1, 0.3, 0.5, 1.0}', search, query) DESC, created_at, id`,
+			strings.Join(terms, " | "))
+	}
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"`
+	}
+	return r.find(ctx, `JOIN blogs_fts ON blogs_fts.blog_id = blogs.id
+		WHERE blogs_fts MATCH ? AND deleted_at IS NULL
+		ORDER BY bm25(blogs_fts, 0, 10, 5, 3, 1), created_at, id`,
+		strings.Join(quoted, " OR "))
+}
+
+// FindRelated returns posts in the same category or sharing a tag.
+func (r *sqlBlogRepository) FindRelated(ctx context.Context, blogID bson.ObjectID, limit int) ([]*domain.Blog, error) {
+	current, err := r.FindByID(ctx, blogID)
+	if err != nil {
+		return nil, err
+	}
+	page, pageArgs := sqlPage(r.db.Dialect, limit, 0)
+	args := append([]any{blogID.Hex(), current.Category, blogID.Hex()}, pageArgs...)
+	return r.find(ctx, `WHERE id <> ? AND deleted_at IS NULL AND (category = ? OR id IN (
+			SELECT other.blog_id FROM blog_tags other
+			JOIN blog_tags this ON this.tag_id = other.tag_id
+			WHERE this.blog_id = ?
+		))`+blogOrder+page, args...)
+}
+
+func (r *sqlBlogRepository) GetCategories(ctx context.Context) ([]string, error) {
+	categories, err := r.values(ctx, "SELECT DISTINCT category FROM blogs WHERE deleted_at IS NULL ORDER BY category")
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{"All"}, categories...), nil
+}
+
+// GetPopularCategories orders categories by post count, breaking ties by
+// name so that results are stable.
+func (r *sqlBlogRepository) GetPopularCategories(ctx context.Context, limit int) ([]string, error) {
+	page, args := sqlPage(r.db.Dialect, limit, 0)
+	return r.values(ctx, `SELECT category FROM blogs WHERE deleted_at IS NULL
+		GROUP BY category ORDER BY COUNT(*) DESC, category`+page, args...)
+}
+
+func (r *sqlBlogRepository) CountByStatus(ctx context.Context) (map[domain.BlogStatus]int64, error) {
+	var trashed, scheduled, live int64
+	err := r.db.QueryRowContext(ctx, `SELECT
+			COUNT(CASE WHEN deleted_at IS NOT NULL THEN 1 END),
+			COUNT(CASE WHEN deleted_at IS NULL AND published_at > ? THEN 1 END),
+			COUNT(CASE WHEN deleted_at IS NULL THEN 1 END)
+		FROM blogs`, sqlTime(time.Now())).Scan(&trashed, &scheduled, &live)
+	if err != nil {
+		return nil, err
+	}
+	return map[domain.BlogStatus]int64{
+		domain.BlogPublished: live - scheduled,
+		domain.BlogScheduled: scheduled,
+		domain.BlogTrashed:   trashed,
+	}, nil
+}
+
+func (r *sqlBlogRepository) LastModified(ctx context.Context) (time.Time, error) {
+	var updated, deleted, published *time.Time
+	err := r.db.QueryRowContext(ctx, `SELECT
+			MAX(updated_at),
+			MAX(deleted_at),
+			MAX(CASE WHEN published_at <= ? THEN published_at END)
+		FROM blogs`, sqlTime(time.Now())).Scan(scanNullTime(&updated), scanNullTime(&deleted), scanNullTime(&published))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return latest(updated, deleted, published), nil
+}
+
+// index replaces a post's tags and refreshes its full-text entry.
+func (r *sqlBlogRepository) index(ctx context.Context, tx *database.SQLTx, blog *domain.Blog) error {
+	id := blog.ID.Hex()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM blog_tags WHERE blog_id = ?", id); err != nil {
+		return err
+	}
+	var tags []string
+	for _, tag := range blog.Tags {
+		if slices.Contains(tags, tag) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING", tag); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO blog_tags (blog_id, tag_id, position) VALUES (?, (SELECT id FROM tags WHERE name = ?), ?)",
+			id, tag, len(tags))
+		if err != nil {
+			return err
+		}
+		tags = append(tags, tag)
+	}
+
+	if r.db.Dialect == database.Postgres {
+		_, err := tx.ExecContext(ctx, `UPDATE blogs SET search =
+			setweight(to_tsvector('english', title), 'A') ||
+			setweight(to_tsvector('english', CAST(? AS text)), 'B') ||
+			setweight(to_tsvector('english', excerpt), 'C') ||
+			setweight(to_tsvector('english', content), 'D')
+			WHERE id = ?`, strings.Join(tags, " "), id)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM blogs_fts WHERE blog_id = ?", id); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO blogs_fts (blog_id, title, tags, excerpt, content) SELECT id, title, ?, excerpt, content FROM blogs WHERE id = ?",
+		strings.Join(tags, " "), id)
+	return err
+}
+
+// deleteUnusedTags removes tags that no post, live or trashed, uses any
+// more.
+func deleteUnusedTags(ctx context.Context, tx *database.SQLTx) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM blog_tags)")
+	return err
+}
+
+// find selects the posts matching clause, which follows FROM blogs, and
+// loads their tags.
+func (r *sqlBlogRepository) find(ctx context.Context, clause string, args ...any) ([]*domain.Blog, error) {
+	blogs, err := sqlFind(ctx, r.db, scanBlog, "SELECT "+qualifiedBlogColumns+" FROM blogs "+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return blogs, r.loadTags(ctx, blogs)
+}
+
+func (r *sqlBlogRepository) findOne(ctx context.Context, clause string, args ...any) (*domain.Blog, error) {
+	blog, err := sqlFindOne(ctx, r.db, scanBlog, "SELECT "+qualifiedBlogColumns+" FROM blogs "+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return blog, r.loadTags(ctx, []*domain.Blog{blog})
+}
+
+// qualifiedBlogColumns names blogColumns unambiguously for queries that
+// join other tables.
+var qualifiedBlogColumns = "blogs." + strings.ReplaceAll(blogColumns, ", ", ", blogs.")
+
+// loadTags fills in the tags of blogs, in the order they were given.
+func (r *sqlBlogRepository) loadTags(ctx context.Context, blogs []*domain.Blog) error {
+	if len(blogs) == 0 {
+		return nil
+	}
+	byID := make(map[bson.ObjectID]*domain.Blog, len(blogs))
+	ids := make([]bson.ObjectID, len(blogs))
+	for i, b := range blogs {
+		byID[b.ID] = b
+		ids[i] = b.ID
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT blog_tags.blog_id, tags.name FROM blog_tags
+		JOIN tags ON tags.id = blog_tags.tag_id
+		WHERE blog_tags.blog_id IN (`+placeholders(len(ids))+`)
+		ORDER BY blog_tags.blog_id, blog_tags.position`, sqlIDArgs(ids)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id bson.ObjectID
+		var tag string
+		if err := rows.Scan(scanID(&id), &tag); err != nil {
+			return err
+		}
+		byID[id].Tags = append(byID[id].Tags, tag)
+	}
+	return rows.Err()
+}
+
+// values runs a query selecting a single text column.
+func (r *sqlBlogRepository) values(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+func scanBlog(row sqlRow) (*domain.Blog, error) {
+	var b domain.Blog
+	var toc []byte
+	err := row.Scan(scanID(&b.ID), &b.Title, &b.Excerpt, &b.Content, &b.ContentHTML, &toc,
+		&b.Author, &b.AuthorImage, &b.ReadTime, &b.WordCount, &b.Category, &b.Image,
+		&b.Featured, &b.ExcerptAuto, &b.ReadTimeAuto, scanTime(&b.CreatedAt), scanTime(&b.UpdatedAt),
+		scanNullTime(&b.PublishedAt), scanNullTime(&b.DeletedAt))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(toc, &b.TOC); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
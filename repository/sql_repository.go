@@ -0,0 +1,353 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tahsin005/codercat-server/database"
+	"github.com/tahsin005/codercat-server/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// The SQL repositories serve both SQLite and PostgreSQL from the schema in
+// migration/sql. IDs are stored as ObjectID hex strings, so they keep the
+// same form, and the same order, as on Mongo.
+
+// sqlTimeLayout is how times are passed to the SQL backends: fixed-width
+// UTC text, which SQLite stores and compares as is and Postgres parses as
+// a timestamptz.
+const sqlTimeLayout = "2006-01-02T15:04:05.000000Z"
+
+func sqlTime(t time.Time) string {
+	return t.UTC().Format(sqlTimeLayout)
+}
+
+func sqlNullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return sqlTime(*t)
+}
+
+// sqlNullString stores an empty string as NULL, as Mongo omits it, so that
+// unique columns only compare values that are set.
+func sqlNullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// timeColumn scans a time from either driver: SQLite returns the stored
+// text and Postgres a time.Time.
+type timeColumn struct {
+	dst  *time.Time
+	null **time.Time
+}
+
+func scanTime(dst *time.Time) sql.Scanner      { return timeColumn{dst: dst} }
+func scanNullTime(dst **time.Time) sql.Scanner { return timeColumn{null: dst} }
+
+func (c timeColumn) Scan(src any) error {
+	var t time.Time
+	switch v := src.(type) {
+	case nil:
+		if c.null == nil {
+			return errors.New("unexpected NULL time")
+		}
+		*c.null = nil
+		return nil
+	case time.Time:
+		t = v.UTC()
+	case string:
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return err
+		}
+	case []byte:
+		return c.Scan(string(v))
+	default:
+		return fmt.Errorf("unsupported time value %T", src)
+	}
+	if c.null != nil {
+		*c.null = &t
+	} else {
+		*c.dst = t
+	}
+	return nil
+}
+
+// idColumn scans an ObjectID stored as hex.
+type idColumn struct {
+	dst  *bson.ObjectID
+	null **bson.ObjectID
+}
+
+func scanID(dst *bson.ObjectID) sql.Scanner      { return idColumn{dst: dst} }
+func scanNullID(dst **bson.ObjectID) sql.Scanner { return idColumn{null: dst} }
+
+func (c idColumn) Scan(src any) error {
+	var hex string
+	switch v := src.(type) {
+	case nil:
+		if c.null == nil {
+			return errors.New("unexpected NULL id")
+		}
+		*c.null = nil
+		return nil
+	case string:
+		hex = v
+	case []byte:
+		hex = string(v)
+	default:
+		return fmt.Errorf("unsupported id value %T", src)
+	}
+	id, err := bson.ObjectIDFromHex(hex)
+	if err != nil {
+		return err
+	}
+	if c.null != nil {
+		*c.null = &id
+	} else {
+		*c.dst = id
+	}
+	return nil
+}
+
+func sqlNullID(id *bson.ObjectID) any {
+	if id == nil {
+		return nil
+	}
+	return id.Hex()
+}
+
+// sqlQuerier is a database.SQLDatabase or a transaction on one.
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sqlRow is a *sql.Row or the current row of *sql.Rows.
+type sqlRow interface {
+	Scan(dest ...any) error
+}
+
+// sqlFind runs query and scans every row it returns.
+func sqlFind[T any](ctx context.Context, q sqlQuerier, scan func(sqlRow) (*T, error), query string, args ...any) ([]*T, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*T
+	for rows.Next() {
+		v, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, v)
+	}
+	return results, rows.Err()
+}
+
+// sqlFindOne runs query and scans its first row, or returns
+// domain.ErrNotFound.
+func sqlFindOne[T any](ctx context.Context, q sqlQuerier, scan func(sqlRow) (*T, error), query string, args ...any) (*T, error) {
+	v, err := scan(q.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return v, err
+}
+
+// sqlAffected turns an update or delete that matched nothing into
+// domain.ErrNotFound.
+func sqlAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// placeholders returns n comma-separated ? placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func sqlArgs[T any](values []T) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+func sqlIDArgs(ids []bson.ObjectID) []any {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id.Hex()
+	}
+	return args
+}
+
+// sqlPage returns the LIMIT and OFFSET clause for a page. A limit of zero
+// or less means no limit, as it does for a Mongo find.
+func sqlPage(dialect database.Dialect, limit, skip int) (string, []any) {
+	var clause string
+	var args []any
+	switch {
+	case limit > 0:
+		clause, args = " LIMIT ?", []any{limit}
+	case skip > 0 && dialect == database.SQLite:
+		// SQLite only accepts OFFSET after a LIMIT.
+		clause = " LIMIT -1"
+	}
+	if skip > 0 {
+		clause += " OFFSET ?"
+		args = append(args, skip)
+	}
+	return clause, args
+}
+
+type sqlSendRepository struct {
+	db *database.SQLDatabase
+}
+
+func NewSQLSendRepository(db *database.SQLDatabase) SendRepository {
+	return &sqlSendRepository{db: db}
+}
+
+const sendColumns = "id, subscriber_id, kind, subject, blog_id, status, error, sent_at"
+
+func (r *sqlSendRepository) Create(ctx context.Context, event *domain.SendEvent) error {
+	event.ID = bson.NewObjectID()
+	_, err := r.db.ExecContext(ctx, "INSERT INTO sends ("+sendColumns+") VALUES ("+placeholders(8)+")",
+		event.ID.Hex(), event.SubscriberID.Hex(), event.Kind, event.Subject, sqlNullID(event.BlogID),
+		event.Status, event.Error, sqlTime(event.SentAt))
+	return err
+}
+
+// FindBySubscriber returns a subscriber's sends, newest first. A limit of
+// zero returns all of them.
+func (r *sqlSendRepository) FindBySubscriber(ctx context.Context, subscriberID bson.ObjectID, limit int) ([]*domain.SendEvent, error) {
+	page, pageArgs := sqlPage(r.db.Dialect, limit, 0)
+	query := "SELECT " + sendColumns + " FROM sends WHERE subscriber_id = ? ORDER BY sent_at DESC, id DESC" + page
+	return sqlFind(ctx, r.db, scanSendEvent, query, append([]any{subscriberID.Hex()}, pageArgs...)...)
+}
+
+func (r *sqlSendRepository) DeleteBySubscriber(ctx context.Context, subscriberID bson.ObjectID) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM sends WHERE subscriber_id = ?", subscriberID.Hex())
+	return err
+}
+
+func scanSendEvent(row sqlRow) (*domain.SendEvent, error) {
+	var e domain.SendEvent
+	err := row.Scan(scanID(&e.ID), scanID(&e.SubscriberID), &e.Kind, &e.Subject, scanNullID(&e.BlogID),
+		&e.Status, &e.Error, scanTime(&e.SentAt))
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+type sqlSuppressionRepository struct {
+	db *database.SQLDatabase
+}
+
+func NewSQLSuppressionRepository(db *database.SQLDatabase) SuppressionRepository {
+	return &sqlSuppressionRepository{db: db}
+}
+
+// Add records hash as suppressed. Adding an existing hash is a no-op.
+func (r *sqlSuppressionRepository) Add(ctx context.Context, hash, reason string) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO suppressions (hash, reason, created_at) VALUES (?, ?, ?) ON CONFLICT (hash) DO NOTHING",
+		hash, reason, sqlTime(time.Now()))
+	return err
+}
+
+func (r *sqlSuppressionRepository) Remove(ctx context.Context, hash string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM suppressions WHERE hash = ?", hash)
+	return err
+}
+
+// FindExisting returns the subset of hashes that are suppressed.
+func (r *sqlSuppressionRepository) FindExisting(ctx context.Context, hashes []string) ([]string, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT hash FROM suppressions WHERE hash IN ("+placeholders(len(hashes))+")", sqlArgs(hashes)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		found = append(found, hash)
+	}
+	return found, rows.Err()
+}
+
+type sqlConsentRepository struct {
+	db *database.SQLDatabase
+}
+
+func NewSQLConsentRepository(db *database.SQLDatabase) ConsentRepository {
+	return &sqlConsentRepository{db: db}
+}
+
+const consentColumns = "id, subscriber_id, action, source, ip, user_agent, text_version, created_at"
+
+func (r *sqlConsentRepository) Create(ctx context.Context, record *domain.ConsentRecord) error {
+	record.ID = bson.NewObjectID()
+	_, err := r.db.ExecContext(ctx, "INSERT INTO consents ("+consentColumns+") VALUES ("+placeholders(8)+")",
+		record.ID.Hex(), record.SubscriberID.Hex(), record.Action, record.Source, record.IP,
+		record.UserAgent, record.TextVersion, sqlTime(record.CreatedAt))
+	return err
+}
+
+func (r *sqlConsentRepository) FindBySubscriber(ctx context.Context, subscriberID bson.ObjectID) ([]*domain.ConsentRecord, error) {
+	return r.FindBySubscribers(ctx, []bson.ObjectID{subscriberID})
+}
+
+// FindBySubscribers returns matching records oldest first, the order they
+// were logged.
+func (r *sqlConsentRepository) FindBySubscribers(ctx context.Context, subscriberIDs []bson.ObjectID) ([]*domain.ConsentRecord, error) {
+	if len(subscriberIDs) == 0 {
+		return nil, nil
+	}
+	query := "SELECT " + consentColumns + " FROM consents WHERE subscriber_id IN (" + placeholders(len(subscriberIDs)) + ") ORDER BY created_at, id"
+	return sqlFind(ctx, r.db, scanConsentRecord, query, sqlIDArgs(subscriberIDs)...)
+}
+
+func (r *sqlConsentRepository) DeleteBySubscriber(ctx context.Context, subscriberID bson.ObjectID) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM consents WHERE subscriber_id = ?", subscriberID.Hex())
+	return err
+}
+
+func scanConsentRecord(row sqlRow) (*domain.ConsentRecord, error) {
+	var c domain.ConsentRecord
+	err := row.Scan(scanID(&c.ID), scanID(&c.SubscriberID), &c.Action, &c.Source, &c.IP,
+		&c.UserAgent, &c.TextVersion, scanTime(&c.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tahsin005/codercat-server/database"
+	"github.com/tahsin005/codercat-server/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type sqlSubscriberRepository struct {
+	db *database.SQLDatabase
+}
+
+func NewSQLSubscriberRepository(db *database.SQLDatabase) SubscriberRepository {
+	return &sqlSubscriberRepository{db: db}
+}
+
+const subscriberColumns = "id, email, email_key, status, confirm_token, manage_token, created_at, confirmed_at"
+
+// CreateSubscriber inserts a new subscriber. It returns domain.ErrConflict
+// if another subscriber already has the same email key.
+func (r *sqlSubscriberRepository) CreateSubscriber(ctx context.Context, subscriber *domain.Subscriber) error {
+	subscriber.ID = bson.NewObjectID()
+	subscriber.CreatedAt = time.Now().UTC()
+	_, err := r.db.ExecContext(ctx, "INSERT INTO subscribers ("+subscriberColumns+") VALUES ("+placeholders(8)+")",
+		subscriber.ID.Hex(), subscriber.Email, subscriber.EmailKey, subscriber.Status,
+		sqlNullString(subscriber.ConfirmToken), sqlNullString(subscriber.ManageToken),
+		sqlTime(subscriber.CreatedAt), sqlNullTime(subscriber.ConfirmedAt))
+	if database.IsUniqueViolation(err) {
+		return domain.ErrConflict
+	}
+	return err
+}
+
+func (r *sqlSubscriberRepository) GetAll(ctx context.Context) ([]*domain.Subscriber, error) {
+	return r.find(ctx, "")
+}
+
+func (r *sqlSubscriberRepository) FindByID(ctx context.Context, id bson.ObjectID) (*domain.Subscriber, error) {
+	return r.findOne(ctx, "id = ?", id.Hex())
+}
+
+func (r *sqlSubscriberRepository) FindByEmailKeys(ctx context.Context, emailKeys []string) ([]*domain.Subscriber, error) {
+	if len(emailKeys) == 0 {
+		return nil, nil
+	}
+	return r.find(ctx, "email_key IN ("+placeholders(len(emailKeys))+")", sqlArgs(emailKeys)...)
+}
+
+// List returns the page of subscribers selected by filter, newest first,
+// along with the total number of matches.
+func (r *sqlSubscriberRepository) List(ctx context.Context, filter domain.SubscriberFilter) ([]*domain.Subscriber, int64, error) {
+	var conditions []string
+	var args []any
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.Search != "" {
+		conditions = append(conditions, `LOWER(email) LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(filter.Search))+"%")
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM subscribers"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page, pageArgs := sqlPage(r.db.Dialect, filter.Limit, filter.Skip)
+	query := "SELECT " + subscriberColumns + " FROM subscribers" + where + " ORDER BY created_at DESC, id DESC" + page
+	subscribers, err := sqlFind(ctx, r.db, scanSubscriber, query, append(args, pageArgs...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return subscribers, total, nil
+}
+
+// likeEscaper escapes the LIKE wildcards so that a search matches its text
+// literally, as the Mongo backend's quoted regex does.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *sqlSubscriberRepository) Delete(ctx context.Context, id bson.ObjectID) error {
+	return sqlAffected(r.db.ExecContext(ctx, "DELETE FROM subscribers WHERE id = ?", id.Hex()))
+}
+
+func (r *sqlSubscriberRepository) FindByStatus(ctx context.Context, status domain.SubscriberStatus) ([]*domain.Subscriber, error) {
+	return r.find(ctx, "status = ?", status)
+}
+
+func (r *sqlSubscriberRepository) FindByEmailKey(ctx context.Context, emailKey string) (*domain.Subscriber, error) {
+	return r.findOne(ctx, "email_key = ?", emailKey)
+}
+
+func (r *sqlSubscriberRepository) FindByConfirmToken(ctx context.Context, token string) (*domain.Subscriber, error) {
+	return r.findOne(ctx, "confirm_token = ?", token)
+}
+
+func (r *sqlSubscriberRepository) FindByManageToken(ctx context.Context, token string) (*domain.Subscriber, error) {
+	return r.findOne(ctx, "manage_token = ?", token)
+}
+
+func (r *sqlSubscriberRepository) Confirm(ctx context.Context, id bson.ObjectID, at time.Time) error {
+	return sqlAffected(r.db.ExecContext(ctx, "UPDATE subscribers SET status = ?, confirmed_at = ?, confirm_token = NULL WHERE id = ?",
+		domain.SubscriberConfirmed, sqlTime(at), id.Hex()))
+}
+
+func (r *sqlSubscriberRepository) CountByStatus(ctx context.Context) (map[domain.SubscriberStatus]int64, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT status, COUNT(*) FROM subscribers GROUP BY status")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[domain.SubscriberStatus]int64{
+		domain.SubscriberPending:   0,
+		domain.SubscriberConfirmed: 0,
+	}
+	for rows.Next() {
+		var status domain.SubscriberStatus
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+// find returns the subscribers matching condition, or every subscriber
+// when it is empty, oldest first.
+func (r *sqlSubscriberRepository) find(ctx context.Context, condition string, args ...any) ([]*domain.Subscriber, error) {
+	query := "SELECT " + subscriberColumns + " FROM subscribers"
+	if condition != "" {
+		query += " WHERE " + condition
+	}
+	return sqlFind(ctx, r.db, scanSubscriber, query+" ORDER BY created_at, id", args...)
+}
+
+func (r *sqlSubscriberRepository) findOne(ctx context.Context, condition string, args ...any) (*domain.Subscriber, error) {
+	return sqlFindOne(ctx, r.db, scanSubscriber, "SELECT "+subscriberColumns+" FROM subscribers WHERE "+condition, args...)
+}
+
+func scanSubscriber(row sqlRow) (*domain.Subscriber, error) {
+	var s domain.Subscriber
+	var confirmToken, manageToken *string
+	err := row.Scan(scanID(&s.ID), &s.Email, &s.EmailKey, &s.Status, &confirmToken, &manageToken,
+		scanTime(&s.CreatedAt), scanNullTime(&s.ConfirmedAt))
+	if err != nil {
+		return nil, err
+	}
+	if confirmToken != nil {
+		s.ConfirmToken = *confirmToken
+	}
+	if manageToken != nil {
+		s.ManageToken = *manageToken
+	}
+	return &s, nil
+}
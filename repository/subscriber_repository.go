@@ -2,17 +2,31 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"regexp"
+	"time"
 
 	"github.com/tahsin005/codercat-server/config"
 	"github.com/tahsin005/codercat-server/database"
 	"github.com/tahsin005/codercat-server/domain"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type SubscriberRepository interface {
 	CreateSubscriber(ctx context.Context, subscriber *domain.Subscriber) error
 	GetAll(ctx context.Context) ([]*domain.Subscriber, error)
+	FindByStatus(ctx context.Context, status domain.SubscriberStatus) ([]*domain.Subscriber, error)
+	FindByEmailKey(ctx context.Context, emailKey string) (*domain.Subscriber, error)
+	FindByConfirmToken(ctx context.Context, token string) (*domain.Subscriber, error)
+	FindByManageToken(ctx context.Context, token string) (*domain.Subscriber, error)
+	Confirm(ctx context.Context, id bson.ObjectID, at time.Time) error
+	FindByID(ctx context.Context, id bson.ObjectID) (*domain.Subscriber, error)
+	FindByEmailKeys(ctx context.Context, emailKeys []string) ([]*domain.Subscriber, error)
+	List(ctx context.Context, filter domain.SubscriberFilter) ([]*domain.Subscriber, int64, error)
+	Delete(ctx context.Context, id bson.ObjectID) error
+	CountByStatus(ctx context.Context) (map[domain.SubscriberStatus]int64, error)
 }
 
 type subscriberRepository struct {
@@ -25,14 +39,117 @@ func NewSubscriberRepository(db *database.Database, cfg *config.Config) Subscrib
 	}
 }
 
+// CreateSubscriber inserts a new subscriber. It returns domain.ErrConflict
+// if another subscriber already has the same email key.
 func (r *subscriberRepository) CreateSubscriber(ctx context.Context, subscriber *domain.Subscriber) error {
 	subscriber.ID = bson.NewObjectID()
+	subscriber.CreatedAt = time.Now().UTC()
 	_, err := r.collection.InsertOne(ctx, subscriber)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrConflict
+	}
 	return err
 }
 
 func (r *subscriberRepository) GetAll(ctx context.Context) ([]*domain.Subscriber, error) {
-	cursor, err := r.collection.Find(ctx, bson.D{})
+	return r.find(ctx, bson.D{})
+}
+
+func (r *subscriberRepository) FindByID(ctx context.Context, id bson.ObjectID) (*domain.Subscriber, error) {
+	return r.findOne(ctx, bson.D{{Key: "_id", Value: id}})
+}
+
+func (r *subscriberRepository) FindByEmailKeys(ctx context.Context, emailKeys []string) ([]*domain.Subscriber, error) {
+	return r.find(ctx, bson.D{{Key: "emailKey", Value: bson.D{{Key: "$in", Value: emailKeys}}}})
+}
+
+// List returns the page of subscribers selected by filter, newest first,
+// along with the total number of matches.
+func (r *subscriberRepository) List(ctx context.Context, filter domain.SubscriberFilter) ([]*domain.Subscriber, int64, error) {
+	query := bson.D{}
+	if filter.Status != "" {
+		query = append(query, bson.E{Key: "status", Value: filter.Status})
+	}
+	if filter.Search != "" {
+		pattern := bson.Regex{Pattern: regexp.QuoteMeta(filter.Search), Options: "i"}
+		query = append(query, bson.E{Key: "email", Value: pattern})
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).SetSkip(int64(filter.Skip))
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	subscribers, err := r.find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return subscribers, total, nil
+}
+
+func (r *subscriberRepository) Delete(ctx context.Context, id bson.ObjectID) error {
+	res, err := r.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *subscriberRepository) FindByStatus(ctx context.Context, status domain.SubscriberStatus) ([]*domain.Subscriber, error) {
+	return r.find(ctx, bson.D{{Key: "status", Value: status}})
+}
+
+func (r *subscriberRepository) FindByEmailKey(ctx context.Context, emailKey string) (*domain.Subscriber, error) {
+	return r.findOne(ctx, bson.D{{Key: "emailKey", Value: emailKey}})
+}
+
+func (r *subscriberRepository) FindByConfirmToken(ctx context.Context, token string) (*domain.Subscriber, error) {
+	return r.findOne(ctx, bson.D{{Key: "confirmToken", Value: token}})
+}
+
+func (r *subscriberRepository) FindByManageToken(ctx context.Context, token string) (*domain.Subscriber, error) {
+	return r.findOne(ctx, bson.D{{Key: "manageToken", Value: token}})
+}
+
+func (r *subscriberRepository) Confirm(ctx context.Context, id bson.ObjectID, at time.Time) error {
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: domain.SubscriberConfirmed},
+			{Key: "confirmedAt", Value: at},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "confirmToken", Value: ""}}},
+	}
+	res, err := r.collection.UpdateByID(ctx, id, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *subscriberRepository) findOne(ctx context.Context, filter bson.D) (*domain.Subscriber, error) {
+	var sub domain.Subscriber
+	err := r.collection.FindOne(ctx, filter).Decode(&sub)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *subscriberRepository) find(ctx context.Context, filter bson.D, opts ...options.Lister[options.FindOptions]) ([]*domain.Subscriber, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -46,5 +163,35 @@ func (r *subscriberRepository) GetAll(ctx context.Context) ([]*domain.Subscriber
 		}
 		subscribers = append(subscribers, &sub)
 	}
-	return subscribers, nil
+	return subscribers, cursor.Err()
+}
+
+func (r *subscriberRepository) CountByStatus(ctx context.Context) (map[domain.SubscriberStatus]int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$status"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := map[domain.SubscriberStatus]int64{
+		domain.SubscriberPending:   0,
+		domain.SubscriberConfirmed: 0,
+	}
+	for cursor.Next(ctx) {
+		var row struct {
+			Status domain.SubscriberStatus `bson:"_id"`
+			Count  int64                   `bson:"count"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		counts[row.Status] = row.Count
+	}
+	return counts, cursor.Err()
 }
@@ -0,0 +1,165 @@
+package repository_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestSubscriberRepository(t *testing.T) {
+	run(t, backends(repository.NewMemorySubscriberRepository, repository.NewSubscriberRepository, repository.NewSQLSubscriberRepository), testSubscriberRepository)
+}
+
+func testSubscriberRepository(t *testing.T, newRepo func(t *testing.T) repository.SubscriberRepository) {
+	ctx := context.Background()
+
+	create := func(t *testing.T, repo repository.SubscriberRepository, email string, status domain.SubscriberStatus) *domain.Subscriber {
+		t.Helper()
+		sub := &domain.Subscriber{
+			Email:        email,
+			EmailKey:     email,
+			Status:       status,
+			ConfirmToken: "confirm-" + email,
+			ManageToken:  "manage-" + email,
+		}
+		must(t, repo.CreateSubscriber(ctx, sub))
+		return sub
+	}
+	emails := func(subs []*domain.Subscriber) []string {
+		out := make([]string, len(subs))
+		for i, s := range subs {
+			out[i] = s.Email
+		}
+		return out
+	}
+	sorted := func(s []string) []string {
+		s = slices.Clone(s)
+		slices.Sort(s)
+		return s
+	}
+
+	t.Run("CreateAndFind", func(t *testing.T) {
+		repo := newRepo(t)
+		sub := create(t, repo, "ada@example.com", domain.SubscriberPending)
+		if sub.ID.IsZero() || sub.CreatedAt.IsZero() {
+			t.Fatalf("CreateSubscriber did not set ID and CreatedAt: %+v", sub)
+		}
+
+		lookups := map[string]func() (*domain.Subscriber, error){
+			"FindByID":           func() (*domain.Subscriber, error) { return repo.FindByID(ctx, sub.ID) },
+			"FindByEmailKey":     func() (*domain.Subscriber, error) { return repo.FindByEmailKey(ctx, "ada@example.com") },
+			"FindByConfirmToken": func() (*domain.Subscriber, error) { return repo.FindByConfirmToken(ctx, "confirm-ada@example.com") },
+			"FindByManageToken":  func() (*domain.Subscriber, error) { return repo.FindByManageToken(ctx, "manage-ada@example.com") },
+		}
+		for name, find := range lookups {
+			got, err := find()
+			must(t, err)
+			if got.ID != sub.ID || got.Email != sub.Email || got.Status != domain.SubscriberPending {
+				t.Errorf("%s = %+v", name, got)
+			}
+		}
+
+		_, err := repo.FindByID(ctx, bson.NewObjectID())
+		assertErr(t, err, domain.ErrNotFound)
+		_, err = repo.FindByEmailKey(ctx, "nobody@example.com")
+		assertErr(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("DuplicateEmailKey", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "ada@example.com", domain.SubscriberPending)
+		dup := &domain.Subscriber{Email: "Ada@Example.com", EmailKey: "ada@example.com", Status: domain.SubscriberPending, ManageToken: "other"}
+		assertErr(t, repo.CreateSubscriber(ctx, dup), domain.ErrConflict)
+	})
+
+	t.Run("Confirm", func(t *testing.T) {
+		repo := newRepo(t)
+		sub := create(t, repo, "ada@example.com", domain.SubscriberPending)
+		at := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+		must(t, repo.Confirm(ctx, sub.ID, at))
+		got, err := repo.FindByID(ctx, sub.ID)
+		must(t, err)
+		if got.Status != domain.SubscriberConfirmed || got.ConfirmedAt == nil || !got.ConfirmedAt.Equal(at) {
+			t.Errorf("after Confirm: status %s, confirmedAt %v", got.Status, got.ConfirmedAt)
+		}
+		_, err = repo.FindByConfirmToken(ctx, "confirm-ada@example.com")
+		assertErr(t, err, domain.ErrNotFound)
+
+		assertErr(t, repo.Confirm(ctx, bson.NewObjectID(), at), domain.ErrNotFound)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "ada@example.com", domain.SubscriberConfirmed)
+		create(t, repo, "bob@test.org", domain.SubscriberPending)
+		create(t, repo, "cy@example.com", domain.SubscriberPending)
+
+		subs, total, err := repo.List(ctx, domain.SubscriberFilter{})
+		must(t, err)
+		if total != 3 || !slices.Equal(emails(subs), []string{"cy@example.com", "bob@test.org", "ada@example.com"}) {
+			t.Errorf("List() = %v (total %d), want newest first", emails(subs), total)
+		}
+
+		subs, total, err = repo.List(ctx, domain.SubscriberFilter{Status: domain.SubscriberPending, Search: "EXAMPLE"})
+		must(t, err)
+		if total != 1 || !slices.Equal(emails(subs), []string{"cy@example.com"}) {
+			t.Errorf("List(pending, EXAMPLE) = %v (total %d)", emails(subs), total)
+		}
+
+		subs, total, err = repo.List(ctx, domain.SubscriberFilter{Skip: 1, Limit: 1})
+		must(t, err)
+		if total != 3 || !slices.Equal(emails(subs), []string{"bob@test.org"}) {
+			t.Errorf("List(skip 1, limit 1) = %v (total %d)", emails(subs), total)
+		}
+	})
+
+	t.Run("Queries", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "ada@example.com", domain.SubscriberConfirmed)
+		create(t, repo, "bob@example.com", domain.SubscriberPending)
+		create(t, repo, "cy@example.com", domain.SubscriberConfirmed)
+
+		all, err := repo.GetAll(ctx)
+		must(t, err)
+		if len(all) != 3 {
+			t.Errorf("GetAll returned %d subscribers, want 3", len(all))
+		}
+		confirmed, err := repo.FindByStatus(ctx, domain.SubscriberConfirmed)
+		must(t, err)
+		if got := sorted(emails(confirmed)); !slices.Equal(got, []string{"ada@example.com", "cy@example.com"}) {
+			t.Errorf("FindByStatus(confirmed) = %v", got)
+		}
+		found, err := repo.FindByEmailKeys(ctx, []string{"bob@example.com", "cy@example.com", "nobody@example.com"})
+		must(t, err)
+		if got := sorted(emails(found)); !slices.Equal(got, []string{"bob@example.com", "cy@example.com"}) {
+			t.Errorf("FindByEmailKeys = %v", got)
+		}
+		counts, err := repo.CountByStatus(ctx)
+		must(t, err)
+		if counts[domain.SubscriberConfirmed] != 2 || counts[domain.SubscriberPending] != 1 {
+			t.Errorf("CountByStatus = %v", counts)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepo(t)
+		sub := create(t, repo, "ada@example.com", domain.SubscriberConfirmed)
+
+		must(t, repo.Delete(ctx, sub.ID))
+		_, err := repo.FindByID(ctx, sub.ID)
+		assertErr(t, err, domain.ErrNotFound)
+		assertErr(t, repo.Delete(ctx, sub.ID), domain.ErrNotFound)
+
+		counts, err := repo.CountByStatus(ctx)
+		must(t, err)
+		if counts[domain.SubscriberConfirmed] != 0 || counts[domain.SubscriberPending] != 0 {
+			t.Errorf("CountByStatus after Delete = %v", counts)
+		}
+	})
+}
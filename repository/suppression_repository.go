@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/tahsin005/codercat-server/config"
+	"github.com/tahsin005/codercat-server/database"
+	"github.com/tahsin005/codercat-server/domain"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type SuppressionRepository interface {
+	Add(ctx context.Context, hash, reason string) error
+	Remove(ctx context.Context, hash string) error
+	FindExisting(ctx context.Context, hashes []string) ([]string, error)
+}
+
+type suppressionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSuppressionRepository(db *database.Database, cfg *config.Config) SuppressionRepository {
+	return &suppressionRepository{
+		collection: db.DB.Collection(cfg.MongoCollNameSuppressions),
+	}
+}
+
+// Add records hash as suppressed. Adding an existing hash is a no-op.
+func (r *suppressionRepository) Add(ctx context.Context, hash, reason string) error {
+	update := bson.D{{Key: "$setOnInsert", Value: domain.Suppression{
+		Hash:      hash,
+		Reason:    reason,
+		CreatedAt: time.Now().UTC(),
+	}}}
+	_, err := r.collection.UpdateByID(ctx, hash, update, options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+func (r *suppressionRepository) Remove(ctx context.Context, hash string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: hash}})
+	return err
+}
+
+// FindExisting returns the subset of hashes that are suppressed.
+func (r *suppressionRepository) FindExisting(ctx context.Context, hashes []string) ([]string, error) {
+	cursor, err := r.collection.Find(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: hashes}}}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var found []string
+	for cursor.Next(ctx) {
+		var s domain.Suppression
+		if err := cursor.Decode(&s); err != nil {
+			return nil, err
+		}
+		found = append(found, s.Hash)
+	}
+	return found, cursor.Err()
+}
@@ -3,10 +3,13 @@ package service
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/logging"
 	"github.com/tahsin005/codercat-server/repository"
-	"github.com/tahsin005/codercat-server/utils"
+	"github.com/tahsin005/codercat-server/tracing"
+	"github.com/tahsin005/codercat-server/validation"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
@@ -23,89 +26,98 @@ type BlogService interface {
 	GetRelatedBlogs(ctx context.Context, id string, limit int) ([]*domain.Blog, error)
 	GetCategories(ctx context.Context) ([]string, error)
 	GetPopularCategories(ctx context.Context, limit int) ([]string, error)
+	GetTrashedBlogs(ctx context.Context) ([]*domain.Blog, error)
+	RestoreBlog(ctx context.Context, id string) error
+	PurgeBlog(ctx context.Context, id string) error
+	PurgeExpiredTrash(ctx context.Context) (int64, error)
+	// GetLastModified returns when any post listing last changed.
+	GetLastModified(ctx context.Context) (time.Time, error)
 }
 
 type blogService struct {
 	repo              repository.BlogRepository
 	subscriberService SubscriberService
-	emailConfig       utils.EmailConfig
+	mailService       MailService
 	templateService   TemplateService
+	markdownService   MarkdownService
 	baseURL           string
+	trashRetention    time.Duration
+	readingWPM        int
 }
 
-func NewBlogService(repo repository.BlogRepository, subscriberService SubscriberService, emailConfig utils.EmailConfig, templateService TemplateService, baseURL string) BlogService {
+func NewBlogService(repo repository.BlogRepository, subscriberService SubscriberService, mailService MailService, templateService TemplateService, markdownService MarkdownService, baseURL string, trashRetention time.Duration, readingWPM int) BlogService {
 	return &blogService{
 		repo:              repo,
 		subscriberService: subscriberService,
-		emailConfig:       emailConfig,
+		mailService:       mailService,
 		templateService:   templateService,
+		markdownService:   markdownService,
 		baseURL:           baseURL,
+		trashRetention:    trashRetention,
+		readingWPM:        readingWPM,
 	}
 }
 
 func (s *blogService) CreateBlog(ctx context.Context, blog *domain.Blog) error {
-	if err := s.repo.Create(ctx, blog); err != nil {
+	if err := validation.ValidateBlog(blog); err != nil {
 		return err
 	}
-
-	// Notify all subscribers
-	subscribers, err := s.subscriberService.GetAll(ctx)
-	if err != nil {
+	if err := s.renderContent(ctx, blog, nil); err != nil {
 		return err
 	}
-
-	if len(subscribers) == 0 {
-		return nil
-	}
-
-	var emails []string
-	for _, sub := range subscribers {
-		emails = append(emails, sub.Email)
-	}
-
-	// Prepare email data
-	emailData := domain.EmailData{
-		Title:          blog.Title,
-		Excerpt:        blog.Excerpt,
-		Author:         blog.Author,
-		Category:       blog.Category,
-		ReadTime:       blog.ReadTime,
-		Tags:           blog.Tags,
-		BlogURL:        fmt.Sprintf("%s/blogs/%s", s.baseURL, blog.ID.Hex()),
+	if blog.PublishedAt == nil {
+		now := time.Now().UTC()
+		blog.PublishedAt = &now
 	}
-
-	// Render HTML template
-	htmlBody, err := s.templateService.RenderEmailTemplate("new_blog", emailData)
-	if err != nil {
+	if err := s.repo.Create(ctx, blog); err != nil {
 		return err
 	}
 
-	subject := fmt.Sprintf("🚀 New Blog Post: %s", blog.Title)
-
-	// Send HTML email in background
-	go utils.SendHTMLEmail(s.emailConfig, emails, subject, htmlBody)
-
-	return nil
+	return s.notifySubscribers(ctx, blog)
 }
 
 func (s *blogService) GetBlogByID(ctx context.Context, id string) (*domain.Blog, error) {
-	oid, err := bson.ObjectIDFromHex(id)
+	oid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	blog, err := s.repo.FindByID(ctx, oid)
 	if err != nil {
 		return nil, err
 	}
-	return s.repo.FindByID(ctx, oid)
+	// Posts written before server-side rendering have no stored HTML.
+	if blog.ContentHTML == "" && blog.Content != "" {
+		if err := s.renderContent(ctx, blog, nil); err != nil {
+			return nil, err
+		}
+	}
+	return blog, nil
 }
 
 func (s *blogService) UpdateBlog(ctx context.Context, id string, blog *domain.Blog) error {
-	oid, err := bson.ObjectIDFromHex(id)
+	oid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	if err := validation.ValidateBlog(blog); err != nil {
+		return err
+	}
+	previous, err := s.repo.FindByID(ctx, oid)
 	if err != nil {
 		return err
 	}
+	if err := s.renderContent(ctx, blog, previous); err != nil {
+		return err
+	}
+	blog.CreatedAt = previous.CreatedAt
+	if blog.PublishedAt == nil {
+		blog.PublishedAt = previous.PublishedAt
+	}
 	return s.repo.Update(ctx, oid, blog)
 }
 
 func (s *blogService) DeleteBlog(ctx context.Context, id string) error {
-	oid, err := bson.ObjectIDFromHex(id)
+	oid, err := parseID(id)
 	if err != nil {
 		return err
 	}
@@ -133,7 +145,7 @@ func (s *blogService) SearchBlogs(ctx context.Context, query string) ([]*domain.
 }
 
 func (s *blogService) GetRelatedBlogs(ctx context.Context, id string, limit int) ([]*domain.Blog, error) {
-	oid, err := bson.ObjectIDFromHex(id)
+	oid, err := parseID(id)
 	if err != nil {
 		return nil, err
 	}
@@ -147,3 +159,146 @@ func (s *blogService) GetCategories(ctx context.Context) ([]string, error) {
 func (s *blogService) GetPopularCategories(ctx context.Context, limit int) ([]string, error) {
 	return s.repo.GetPopularCategories(ctx, limit)
 }
+
+func (s *blogService) GetTrashedBlogs(ctx context.Context) ([]*domain.Blog, error) {
+	return s.repo.FindTrashed(ctx)
+}
+
+func (s *blogService) RestoreBlog(ctx context.Context, id string) error {
+	oid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	return s.repo.Restore(ctx, oid)
+}
+
+func (s *blogService) PurgeBlog(ctx context.Context, id string) error {
+	oid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	return s.repo.Purge(ctx, oid)
+}
+
+// PurgeExpiredTrash permanently removes posts that have been in the trash
+// for longer than the configured retention period.
+func (s *blogService) PurgeExpiredTrash(ctx context.Context) (int64, error) {
+	return s.repo.PurgeDeletedBefore(ctx, time.Now().UTC().Add(-s.trashRetention))
+}
+
+func (s *blogService) GetLastModified(ctx context.Context) (time.Time, error) {
+	return s.repo.LastModified(ctx)
+}
+
+// notifySubscribers emails a newly created post to confirmed subscribers.
+func (s *blogService) notifySubscribers(ctx context.Context, blog *domain.Blog) (err error) {
+	ctx, span := tracing.Start(ctx, "BlogService.notifySubscribers")
+	defer func() { tracing.End(span, err) }()
+
+	// Notify confirmed subscribers
+	subscribers, err := s.subscriberService.GetConfirmed(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(subscribers) == 0 {
+		return nil
+	}
+
+	// Prepare email data
+	emailData := domain.EmailData{
+		Title:    blog.Title,
+		Excerpt:  blog.Excerpt,
+		Author:   blog.Author,
+		Category: blog.Category,
+		ReadTime: blog.ReadTime,
+		Tags:     blog.Tags,
+		BlogURL:  fmt.Sprintf("%s/blogs/%s", s.baseURL, blog.ID.Hex()),
+	}
+
+	// Render HTML template
+	_, renderSpan := tracing.Start(ctx, "template.Render new_blog")
+	htmlBody, err := s.templateService.RenderEmailTemplate("new_blog", emailData)
+	tracing.End(renderSpan, err)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("🚀 New Blog Post: %s", blog.Title)
+
+	// Send HTML email to each subscriber in background
+	blogID := blog.ID
+	s.mailService.SendAsync(ctx, Mail{
+		Kind:       domain.SendNewBlog,
+		Subject:    subject,
+		HTMLBody:   htmlBody,
+		BlogID:     &blogID,
+		Recipients: subscribers,
+	})
+
+	return nil
+}
+
+// renderContent fills the fields derived from the post's Markdown source.
+// An excerpt or read time supplied by the author is kept as an override;
+// previous is the stored post on update and lets a generated value that the
+// client merely echoed back be regenerated from the new content.
+func (s *blogService) renderContent(ctx context.Context, blog, previous *domain.Blog) error {
+	_, span := tracing.Start(ctx, "markdown.Render")
+	rendered, err := s.markdownService.Render(blog.Content)
+	tracing.End(span, err)
+	if err != nil {
+		return err
+	}
+	blog.ContentHTML = rendered.HTML
+	blog.TOC = rendered.TOC
+	blog.WordCount = rendered.Stats.Words + rendered.Stats.CodeWords
+
+	if blog.Excerpt == "" || (previous != nil && previous.ExcerptAuto && blog.Excerpt == previous.Excerpt) {
+		blog.Excerpt = rendered.Excerpt
+		blog.ExcerptAuto = true
+	} else {
+		blog.ExcerptAuto = false
+	}
+
+	if blog.ReadTime == "" || (previous != nil && previous.ReadTimeAuto && blog.ReadTime == previous.ReadTime) {
+		blog.ReadTime = formatReadTime(rendered.Stats.ReadingMinutes(s.readingWPM))
+		blog.ReadTimeAuto = true
+	} else {
+		blog.ReadTimeAuto = false
+	}
+	return nil
+}
+
+// parseID converts a hex path parameter into an ObjectID, reporting
+// malformed input as domain.ErrInvalidID.
+func parseID(id string) (bson.ObjectID, error) {
+	oid, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return bson.NilObjectID, fmt.Errorf("%w: %q", domain.ErrInvalidID, id)
+	}
+	return oid, nil
+}
+
+// RunTrashPurger calls PurgeExpiredTrash every interval until ctx is done,
+// beating heartbeat with the result of each run.
+func RunTrashPurger(ctx context.Context, s BlogService, interval time.Duration, heartbeat *Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.PurgeExpiredTrash(ctx)
+			heartbeat.Beat(err)
+			if err != nil {
+				logging.FromContext(ctx).ErrorContext(ctx, "failed to purge expired trash", logging.Err(err))
+				continue
+			}
+			if n > 0 {
+				logging.FromContext(ctx).InfoContext(ctx, "purged expired posts from trash", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/tahsin005/codercat-server/cache"
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/logging"
+	"github.com/tahsin005/codercat-server/metrics"
+	"golang.org/x/sync/singleflight"
+)
+
+// cachedBlogService serves the homepage listings from a cache.Store and
+// clears it whenever a post changes. The store is best effort: when it
+// fails, requests go to the wrapped service instead.
+type cachedBlogService struct {
+	BlogService
+	store  cache.Store
+	ttl    time.Duration
+	flight singleflight.Group
+	// generation counts invalidations, so that a load which started
+	// before a write neither stores nor shares its stale result.
+	generation atomic.Uint64
+}
+
+// NewCachedBlogService caches the featured and recent posts, the category
+// lists and when posts last changed for ttl. Concurrent misses for the
+// same key share a single load.
+func NewCachedBlogService(next BlogService, store cache.Store, ttl time.Duration) BlogService {
+	return &cachedBlogService{BlogService: next, store: store, ttl: ttl}
+}
+
+func (s *cachedBlogService) GetFeaturedBlogs(ctx context.Context) ([]*domain.Blog, error) {
+	return cached(ctx, s, "blogs:featured", func(ctx context.Context) ([]*domain.Blog, error) {
+		return s.BlogService.GetFeaturedBlogs(ctx)
+	})
+}
+
+func (s *cachedBlogService) GetRecentBlogs(ctx context.Context, limit int) ([]*domain.Blog, error) {
+	return cached(ctx, s, "blogs:recent:"+strconv.Itoa(limit), func(ctx context.Context) ([]*domain.Blog, error) {
+		return s.BlogService.GetRecentBlogs(ctx, limit)
+	})
+}
+
+func (s *cachedBlogService) GetCategories(ctx context.Context) ([]string, error) {
+	return cached(ctx, s, "categories", func(ctx context.Context) ([]string, error) {
+		return s.BlogService.GetCategories(ctx)
+	})
+}
+
+func (s *cachedBlogService) GetPopularCategories(ctx context.Context, limit int) ([]string, error) {
+	return cached(ctx, s, "categories:popular:"+strconv.Itoa(limit), func(ctx context.Context) ([]string, error) {
+		return s.BlogService.GetPopularCategories(ctx, limit)
+	})
+}
+
+func (s *cachedBlogService) GetLastModified(ctx context.Context) (time.Time, error) {
+	return cached(ctx, s, "lastModified", func(ctx context.Context) (time.Time, error) {
+		return s.BlogService.GetLastModified(ctx)
+	})
+}
+
+func (s *cachedBlogService) CreateBlog(ctx context.Context, blog *domain.Blog) error {
+	return s.invalidate(ctx, s.BlogService.CreateBlog(ctx, blog))
+}
+
+func (s *cachedBlogService) UpdateBlog(ctx context.Context, id string, blog *domain.Blog) error {
+	return s.invalidate(ctx, s.BlogService.UpdateBlog(ctx, id, blog))
+}
+
+func (s *cachedBlogService) DeleteBlog(ctx context.Context, id string) error {
+	return s.invalidate(ctx, s.BlogService.DeleteBlog(ctx, id))
+}
+
+func (s *cachedBlogService) RestoreBlog(ctx context.Context, id string) error {
+	return s.invalidate(ctx, s.BlogService.RestoreBlog(ctx, id))
+}
+
+func (s *cachedBlogService) PurgeBlog(ctx context.Context, id string) error {
+	return s.invalidate(ctx, s.BlogService.PurgeBlog(ctx, id))
+}
+
+// PurgeExpiredTrash only invalidates when it purged something, since it
+// runs on a timer whether or not anything is in the trash.
+func (s *cachedBlogService) PurgeExpiredTrash(ctx context.Context) (int64, error) {
+	n, err := s.BlogService.PurgeExpiredTrash(ctx)
+	if n > 0 {
+		s.invalidate(ctx, nil)
+	}
+	return n, err
+}
+
+// invalidate clears the cache after a successful write and passes err
+// through. The write itself has happened either way, so a failure to
+// clear is logged rather than returned.
+func (s *cachedBlogService) invalidate(ctx context.Context, err error) error {
+	if err != nil {
+		return err
+	}
+	s.generation.Add(1)
+	if err := s.store.Clear(ctx); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "failed to clear the cache", logging.Err(err))
+	}
+	return nil
+}
+
+// loadTimeout bounds a shared load, which no longer ends with the request
+// that started it.
+const loadTimeout = 10 * time.Second
+
+// cached returns the value stored under key, or loads, stores and returns
+// it. Values are stored as JSON so that a shared store can hold them, and
+// every caller decodes its own copy.
+//
+// A load is shared by every caller missing the same key, so it runs on a
+// context detached from the first caller's: one client disconnecting must
+// not fail the others. Each caller still stops waiting when its own
+// context ends.
+func cached[T any](ctx context.Context, s *cachedBlogService, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	log := logging.FromContext(ctx)
+	var v T
+	data, ok, err := s.store.Get(ctx, key)
+	if err != nil {
+		log.WarnContext(ctx, "failed to read the cache", "key", key, logging.Err(err))
+	}
+	metrics.ObserveCache(ok)
+	if !ok {
+		generation := s.generation.Load()
+		flightKey := strconv.FormatUint(generation, 10) + "/" + key
+		ch := s.flight.DoChan(flightKey, func() (any, error) {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+			defer cancel()
+			v, err := load(ctx)
+			if err != nil {
+				return nil, err
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			if s.generation.Load() == generation {
+				if err := s.store.Set(ctx, key, data, s.ttl); err != nil {
+					log.WarnContext(ctx, "failed to write the cache", "key", key, logging.Err(err))
+				}
+				// An invalidation between the check and the Set would leave
+				// the old value cached for the whole TTL.
+				if s.generation.Load() != generation {
+					if err := s.store.Delete(ctx, key); err != nil {
+						log.WarnContext(ctx, "failed to drop a stale cache entry", "key", key, logging.Err(err))
+					}
+				}
+			}
+			return data, nil
+		})
+		select {
+		case res := <-ch:
+			if res.Err != nil {
+				return v, res.Err
+			}
+			data = res.Val.([]byte)
+		case <-ctx.Done():
+			return v, ctx.Err()
+		}
+	}
+	err = json.Unmarshal(data, &v)
+	return v, err
+}
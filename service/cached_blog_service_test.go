@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tahsin005/codercat-server/cache"
+)
+
+// slowBlogService serves categories once release is closed.
+type slowBlogService struct {
+	BlogService
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowBlogService) GetCategories(ctx context.Context) ([]string, error) {
+	if s.calls.Add(1) == 1 {
+		close(s.started)
+	}
+	select {
+	case <-s.release:
+		return []string{"go"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestCachedLoadOutlivesFirstCaller(t *testing.T) {
+	next := &slowBlogService{started: make(chan struct{}), release: make(chan struct{})}
+	s := NewCachedBlogService(next, cache.NewMemoryStore(10), time.Minute)
+
+	type result struct {
+		categories []string
+		err        error
+	}
+	first, second := make(chan result, 1), make(chan result, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		categories, err := s.GetCategories(ctx)
+		first <- result{categories, err}
+	}()
+	<-next.started
+	go func() {
+		categories, err := s.GetCategories(context.Background())
+		second <- result{categories, err}
+	}()
+	// Let the second caller join the load before the first one leaves.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if r := <-first; !errors.Is(r.err, context.Canceled) {
+		t.Errorf("first caller got %v, %v, want context.Canceled", r.categories, r.err)
+	}
+
+	close(next.release)
+	if r := <-second; r.err != nil || len(r.categories) != 1 || r.categories[0] != "go" {
+		t.Errorf("second caller got %v, %v", r.categories, r.err)
+	}
+	if calls := next.calls.Load(); calls != 1 {
+		t.Errorf("categories loaded %d times, want once", calls)
+	}
+	if categories, err := s.GetCategories(context.Background()); err != nil || len(categories) != 1 {
+		t.Errorf("cached categories = %v, %v", categories, err)
+	}
+}
+
+// racingStore runs beforeSet ahead of every Set.
+type racingStore struct {
+	cache.Store
+	beforeSet func()
+}
+
+func (s *racingStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.beforeSet()
+	return s.Store.Set(ctx, key, value, ttl)
+}
+
+func TestCachedLoadDroppedWhenInvalidatedWhileStoring(t *testing.T) {
+	ctx := context.Background()
+	next := &slowBlogService{started: make(chan struct{}), release: make(chan struct{})}
+	close(next.release)
+	store := &racingStore{Store: cache.NewMemoryStore(10)}
+	s := NewCachedBlogService(next, store, time.Minute).(*cachedBlogService)
+	store.beforeSet = func() { s.invalidate(ctx, nil) }
+
+	if _, err := s.GetCategories(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.Get(ctx, "categories"); ok {
+		t.Error("categories loaded before an invalidation are still cached")
+	}
+}
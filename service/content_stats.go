@@ -0,0 +1,137 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+const (
+	// codeReadingFactor slows reading speed for code blocks, which are read
+	// more carefully than prose.
+	codeReadingFactor = 0.5
+
+	// minExcerptWords is the shortest paragraph considered meaningful enough
+	// to serve as an excerpt.
+	minExcerptWords = 8
+
+	maxExcerptLength = 200
+)
+
+// ContentStats summarises the readable content of a Markdown document.
+type ContentStats struct {
+	Words     int
+	CodeWords int
+	Images    int
+}
+
+// ReadingMinutes estimates reading time at wpm words per minute. Code is
+// read at half speed and each image adds viewing time, starting at twelve
+// seconds and decreasing by one second per image down to three.
+func (c ContentStats) ReadingMinutes(wpm int) int {
+	if wpm <= 0 {
+		wpm = 230
+	}
+	seconds := float64(c.Words) / float64(wpm) * 60
+	seconds += float64(c.CodeWords) / (float64(wpm) * codeReadingFactor) * 60
+	for i := 0; i < c.Images; i++ {
+		seconds += math.Max(12-float64(i), 3)
+	}
+	minutes := int(math.Ceil(seconds / 60))
+	if minutes < 1 {
+		minutes = 1
+	}
+	return minutes
+}
+
+func formatReadTime(minutes int) string {
+	return fmt.Sprintf("%d min read", minutes)
+}
+
+func contentStats(doc ast.Node, src []byte) ContentStats {
+	var stats ContentStats
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := n.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				seg := lines.At(i)
+				stats.CodeWords += len(strings.Fields(string(seg.Value(src))))
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.Image:
+			stats.Images++
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			stats.Words += len(strings.Fields(string(t.Segment.Value(src))))
+		case *ast.String:
+			stats.Words += len(strings.Fields(string(t.Value)))
+		}
+		return ast.WalkContinue, nil
+	})
+	return stats
+}
+
+// excerpt returns the first top-level paragraph with enough prose to
+// summarise the post, falling back to the first paragraph with any text.
+// Images are ignored and the result is truncated on a word boundary.
+func excerpt(doc ast.Node, src []byte) string {
+	var fallback string
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if n.Kind() != ast.KindParagraph {
+			continue
+		}
+		text := proseText(n, src)
+		if text == "" {
+			continue
+		}
+		if len(strings.Fields(text)) >= minExcerptWords {
+			return truncateWords(text, maxExcerptLength)
+		}
+		if fallback == "" {
+			fallback = text
+		}
+	}
+	return truncateWords(fallback, maxExcerptLength)
+}
+
+// proseText is like plainText but skips image alt text.
+func proseText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Image:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			b.Write(t.Segment.Value(src))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func truncateWords(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	cut := string(runes[:max])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.-") + "…"
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type HealthStatus string
+
+const (
+	HealthOK HealthStatus = "ok"
+	// HealthDegraded means a non-critical component is failing; the server
+	// still accepts traffic.
+	HealthDegraded HealthStatus = "degraded"
+	HealthFail     HealthStatus = "fail"
+)
+
+// HealthCheck probes one dependency. Only failing critical checks make the
+// server unready.
+type HealthCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+type ComponentHealth struct {
+	Status    HealthStatus `json:"status"`
+	Critical  bool         `json:"critical"`
+	LatencyMs int64        `json:"latencyMs"`
+	Error     string       `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status     HealthStatus               `json:"status"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+type HealthService interface {
+	// Ready runs every check concurrently, each bounded by the check
+	// timeout.
+	Ready(ctx context.Context) HealthReport
+	// SetShuttingDown makes Ready fail from now on so load balancers stop
+	// routing new traffic while in-flight requests drain.
+	SetShuttingDown()
+}
+
+type healthService struct {
+	timeout      time.Duration
+	checks       []HealthCheck
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService(timeout time.Duration, checks ...HealthCheck) HealthService {
+	return &healthService{timeout: timeout, checks: checks}
+}
+
+func (s *healthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+func (s *healthService) Ready(ctx context.Context) HealthReport {
+	report := HealthReport{Status: HealthOK, Components: make(map[string]ComponentHealth, len(s.checks)+1)}
+	if s.shuttingDown.Load() {
+		report.Status = HealthFail
+		report.Components["shutdown"] = ComponentHealth{Status: HealthFail, Critical: true, Error: "server is shutting down"}
+		return report
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := s.run(ctx, check)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[check.Name] = c
+			if c.Status == HealthFail {
+				if check.Critical {
+					report.Status = HealthFail
+				} else if report.Status == HealthOK {
+					report.Status = HealthDegraded
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (s *healthService) run(ctx context.Context, check HealthCheck) ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Check(ctx)
+	c := ComponentHealth{
+		Status:    HealthOK,
+		Critical:  check.Critical,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		c.Status = HealthFail
+		c.Error = err.Error()
+	}
+	return c
+}
+
+// Heartbeat records when a background worker last ran and whether that run
+// failed. A worker whose last run failed, or that has not beaten for two
+// intervals, is reported as unhealthy.
+type Heartbeat struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	last    time.Time
+	lastErr error
+}
+
+func NewHeartbeat(interval time.Duration) *Heartbeat {
+	h := &Heartbeat{interval: interval}
+	h.Beat(nil)
+	return h
+}
+
+// Beat records a run of the worker and its result.
+func (h *Heartbeat) Beat(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.last, h.lastErr = time.Now(), err
+}
+
+func (h *Heartbeat) Check(ctx context.Context) error {
+	h.mu.Lock()
+	last, lastErr := h.last, h.lastErr
+	h.mu.Unlock()
+	if age := time.Since(last); age > 2*h.interval {
+		return fmt.Errorf("stalled: last ran %s ago", age.Round(time.Second))
+	}
+	if lastErr != nil {
+		return fmt.Errorf("last run failed: %w", lastErr)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	h := NewHeartbeat(time.Hour)
+	if err := h.Check(ctx); err != nil {
+		t.Fatalf("fresh heartbeat: %v", err)
+	}
+
+	h.Beat(errors.New("connection refused"))
+	if err := h.Check(ctx); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("after a failed run: %v", err)
+	}
+	h.Beat(nil)
+	if err := h.Check(ctx); err != nil {
+		t.Errorf("after a successful run: %v", err)
+	}
+
+	h = NewHeartbeat(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if err := h.Check(ctx); err == nil || !strings.Contains(err.Error(), "stalled") {
+		t.Errorf("stale heartbeat: %v", err)
+	}
+}
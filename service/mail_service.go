@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/logging"
+	"github.com/tahsin005/codercat-server/metrics"
+	"github.com/tahsin005/codercat-server/repository"
+	"github.com/tahsin005/codercat-server/tracing"
+	"github.com/tahsin005/codercat-server/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Mail is a rendered email addressed to one or more subscribers.
+type Mail struct {
+	Kind       domain.SendKind
+	Subject    string
+	HTMLBody   string
+	BlogID     *bson.ObjectID
+	Recipients []*domain.Subscriber
+}
+
+type MailService interface {
+	// SendAsync delivers mail to each recipient individually in the
+	// background and records the outcome in their send history. The sends
+	// outlive ctx's cancellation and are traced in a trace of their own,
+	// linked to the span in ctx.
+	SendAsync(ctx context.Context, mail Mail)
+	// Close stops accepting mail and waits for pending sends to finish. If
+	// ctx ends first, the recipients still waiting are recorded as failed
+	// so the send history shows who was missed.
+	Close(ctx context.Context) error
+	// QueueDepth is the number of recipients still waiting to be sent to.
+	QueueDepth() int
+}
+
+// EmailSender delivers a single HTML email.
+type EmailSender interface {
+	SendHTML(ctx context.Context, to []string, subject, htmlBody string) error
+}
+
+type smtpSender struct {
+	config utils.EmailConfig
+}
+
+func NewSMTPSender(config utils.EmailConfig) EmailSender {
+	return &smtpSender{config: config}
+}
+
+func (s *smtpSender) SendHTML(_ context.Context, to []string, subject, htmlBody string) error {
+	return utils.SendHTMLEmail(s.config, to, subject, htmlBody)
+}
+
+// ErrMailDisabled is returned by the sender from NewLogSender, so that the
+// sends it drops are recorded as skipped rather than sent.
+var ErrMailDisabled = errors.New("not sent: mail is disabled")
+
+type logSender struct{}
+
+// NewLogSender returns an EmailSender that only logs what it would have
+// sent, for running without SMTP credentials. Every send fails with
+// ErrMailDisabled.
+func NewLogSender() EmailSender {
+	return logSender{}
+}
+
+func (logSender) SendHTML(ctx context.Context, to []string, subject, _ string) error {
+	logging.FromContext(ctx).InfoContext(ctx, "email not sent: mail is disabled", "recipients", len(to), "subject", subject)
+	return ErrMailDisabled
+}
+
+type instrumentedSender struct {
+	next EmailSender
+}
+
+// NewInstrumentedSender counts successes and failures in metrics, leaving
+// out sends skipped because mail is disabled. Sends are not traced one by
+// one; the mail service records them as span events.
+func NewInstrumentedSender(next EmailSender) EmailSender {
+	return &instrumentedSender{next: next}
+}
+
+func (s *instrumentedSender) SendHTML(ctx context.Context, to []string, subject, htmlBody string) error {
+	err := s.next.SendHTML(ctx, to, subject, htmlBody)
+	if !errors.Is(err, ErrMailDisabled) {
+		metrics.ObserveEmail(err)
+	}
+	return err
+}
+
+// errShutdown is recorded for recipients skipped because the server was
+// shutting down.
+var errShutdown = errors.New("not sent: server shutting down")
+
+// abortGrace bounds how long Close waits for sends to checkpoint after its
+// context has ended.
+const abortGrace = 5 * time.Second
+
+type mailService struct {
+	sendRepo repository.SendRepository
+	sender   EmailSender
+	queued   atomic.Int64
+
+	mu      sync.Mutex
+	closed  bool
+	pending sync.WaitGroup
+	abort   chan struct{}
+}
+
+func NewMailService(sendRepo repository.SendRepository, sender EmailSender) MailService {
+	return &mailService{
+		sendRepo: sendRepo,
+		sender:   sender,
+		abort:    make(chan struct{}),
+	}
+}
+
+func (s *mailService) QueueDepth() int {
+	return int(s.queued.Load())
+}
+
+func (s *mailService) SendAsync(ctx context.Context, mail Mail) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		logging.FromContext(ctx).WarnContext(ctx, "dropping email: mail service is closed", "kind", mail.Kind, "recipients", len(mail.Recipients))
+		return
+	}
+	s.pending.Add(1)
+	s.queued.Add(int64(len(mail.Recipients)))
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer s.pending.Done()
+		s.send(ctx, mail)
+	}()
+}
+
+func (s *mailService) Close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Sends in progress cannot be interrupted; give them a moment to
+	// finish and record the recipients they will skip.
+	close(s.abort)
+	select {
+	case <-done:
+	case <-time.After(abortGrace):
+	}
+	return ctx.Err()
+}
+
+// send delivers mail in a new trace linked to the span in ctx, since it
+// runs long after the request that triggered it. Recipients are recorded as
+// events on the batch span rather than as child spans, which would run to
+// thousands for a newsletter.
+func (s *mailService) send(ctx context.Context, mail Mail) {
+	ctx, span := tracing.Start(ctx, "mail.send",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithAttributes(
+			attribute.String("mail.kind", string(mail.Kind)),
+			attribute.Int("mail.recipients", len(mail.Recipients)),
+		),
+	)
+	defer span.End()
+	ctx = tracing.Unsampled(ctx)
+
+	for i, recipient := range mail.Recipients {
+		select {
+		case <-s.abort:
+			span.AddEvent("mail.skipped", trace.WithAttributes(attribute.Int("mail.recipients", len(mail.Recipients)-i)))
+			s.recordSkipped(ctx, mail, mail.Recipients[i:])
+			return
+		default:
+		}
+
+		event := &domain.SendEvent{
+			SubscriberID: recipient.ID,
+			Kind:         mail.Kind,
+			Subject:      mail.Subject,
+			BlogID:       mail.BlogID,
+			Status:       domain.SendSent,
+		}
+		err := s.sender.SendHTML(ctx, []string{recipient.Email}, mail.Subject, mail.HTMLBody)
+		s.queued.Add(-1)
+		switch {
+		case errors.Is(err, ErrMailDisabled):
+			event.Status = domain.SendSkipped
+			event.Error = err.Error()
+		case err != nil:
+			logging.FromContext(ctx).ErrorContext(ctx, "failed to send email", "kind", mail.Kind, "subscriber_id", recipient.ID.Hex(), logging.Err(err))
+			event.Status = domain.SendFailed
+			event.Error = err.Error()
+		}
+		event.SentAt = time.Now().UTC()
+		attrs := []attribute.KeyValue{
+			attribute.String("subscriber.id", recipient.ID.Hex()),
+			attribute.String("mail.status", string(event.Status)),
+		}
+		if event.Error != "" {
+			attrs = append(attrs, attribute.String("error.message", event.Error))
+		}
+		span.AddEvent("mail.recipient", trace.WithAttributes(attrs...))
+		if err := s.sendRepo.Create(ctx, event); err != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "failed to record send", "subscriber_id", recipient.ID.Hex(), logging.Err(err))
+		}
+	}
+}
+
+func (s *mailService) recordSkipped(ctx context.Context, mail Mail, recipients []*domain.Subscriber) {
+	logging.FromContext(ctx).WarnContext(ctx, "shutting down with emails unsent", "kind", mail.Kind, "recipients", len(recipients))
+	s.queued.Add(-int64(len(recipients)))
+	now := time.Now().UTC()
+	for _, recipient := range recipients {
+		event := &domain.SendEvent{
+			SubscriberID: recipient.ID,
+			Kind:         mail.Kind,
+			Subject:      mail.Subject,
+			BlogID:       mail.BlogID,
+			Status:       domain.SendFailed,
+			Error:        errShutdown.Error(),
+			SentAt:       now,
+		}
+		if err := s.sendRepo.Create(ctx, event); err != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "failed to record skipped send", "subscriber_id", recipient.ID.Hex(), logging.Err(err))
+		}
+	}
+}
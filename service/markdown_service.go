@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+)
+
+// highlightStyle is the Chroma style used for the stylesheet served to
+// clients. Code blocks are rendered with CSS classes rather than inline
+// styles so that the sanitizer does not need to allow style attributes.
+const highlightStyle = "github-dark"
+
+type RenderedMarkdown struct {
+	HTML    string
+	TOC     []domain.TOCEntry
+	Stats   ContentStats
+	Excerpt string
+}
+
+type MarkdownService interface {
+	Render(source string) (*RenderedMarkdown, error)
+	HighlightCSS() (string, error)
+}
+
+type markdownService struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+}
+
+func NewMarkdownService() MarkdownService {
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+			extension.Strikethrough,
+			extension.Linkify,
+			extension.TaskList,
+			extension.Footnote,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle(highlightStyle),
+				highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+			),
+		),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		// Raw HTML is passed through here and cleaned by the sanitizer.
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+	return &markdownService{md: md, policy: newContentPolicy()}
+}
+
+func newContentPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	classNames := regexp.MustCompile(`^[\w\- ]+$`)
+	p.AllowAttrs("class").Matching(classNames).OnElements("pre", "code", "span", "div", "a", "sup")
+	p.AllowAttrs("id").Matching(bluemonday.SpaceSeparatedTokens).OnElements("h1", "h2", "h3", "h4", "h5", "h6", "sup", "li")
+	p.AllowAttrs("role").Matching(regexp.MustCompile(`^doc-[a-z]+$`)).OnElements("a", "div")
+	p.AllowAttrs("tabindex").Matching(bluemonday.Integer).OnElements("pre")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	return p
+}
+
+// Render converts CommonMark/GFM source into sanitized HTML and extracts a
+// table of contents, reading statistics and a plain-text excerpt.
+func (s *markdownService) Render(source string) (*RenderedMarkdown, error) {
+	src := []byte(source)
+	doc := s.md.Parser().Parse(text.NewReader(src))
+
+	var buf bytes.Buffer
+	if err := s.md.Renderer().Render(&buf, src, doc); err != nil {
+		return nil, err
+	}
+
+	return &RenderedMarkdown{
+		HTML:    s.policy.Sanitize(buf.String()),
+		TOC:     tableOfContents(doc, src),
+		Stats:   contentStats(doc, src),
+		Excerpt: excerpt(doc, src),
+	}, nil
+}
+
+func (s *markdownService) HighlightCSS() (string, error) {
+	var buf bytes.Buffer
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	if err := formatter.WriteCSS(&buf, styles.Get(highlightStyle)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func tableOfContents(doc ast.Node, src []byte) []domain.TOCEntry {
+	var toc []domain.TOCEntry
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		entry := domain.TOCEntry{Level: heading.Level, Text: plainText(heading, src)}
+		if id, ok := heading.AttributeString("id"); ok {
+			if b, ok := id.([]byte); ok {
+				entry.ID = string(b)
+			}
+		}
+		toc = append(toc, entry)
+		return ast.WalkSkipChildren, nil
+	})
+	return toc
+}
+
+// plainText concatenates the text content of n's descendants, dropping any
+// inline markup such as emphasis or links.
+func plainText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Text:
+			b.Write(t.Segment.Value(src))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderSanitizes(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		banned []string
+	}{
+		{"script element", "Hi\n\n<script>alert(1)</script>\n", []string{"<script", "alert(1)"}},
+		{"inline script", "Hi <script>alert(1)</script> there", []string{"<script", "alert(1)"}},
+		{"javascript link", "[click](javascript:alert(1))", []string{"javascript:"}},
+		{"javascript href", `<a href="javascript:alert(1)">click</a>`, []string{"javascript:"}},
+		{"entity-encoded javascript href", `<a href="&#106;avascript:alert(1)">click</a>`, []string{"avascript:"}},
+		{"onerror attribute", `<img src="x.png" onerror="alert(1)">`, []string{"onerror", "alert(1)"}},
+		{"onclick on a heading", `<h2 id="x" onclick="alert(1)">Hi</h2>`, []string{"onclick", "alert(1)"}},
+		{"iframe", `<iframe src="https://evil.example"></iframe>`, []string{"<iframe"}},
+		{"style attribute", `<span style="background:url(javascript:alert(1))">x</span>`, []string{"style=", "javascript:"}},
+	}
+	s := NewMarkdownService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := s.Render(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, banned := range tt.banned {
+				if strings.Contains(strings.ToLower(out.HTML), banned) {
+					t.Errorf("rendered HTML contains %q:\n%s", banned, out.HTML)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderKeepsHighlightingAndHeadingIDs(t *testing.T) {
+	out, err := NewMarkdownService().Render("# Getting Started\n\n## Install it\n\n```go\nfunc main() {}\n```\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<h1 id="getting-started">`,
+		`<h2 id="install-it">`,
+		`<pre class="chroma"`,
+		`<span class="kd">func</span>`,
+		`<span class="nf">main</span>`,
+	} {
+		if !strings.Contains(out.HTML, want) {
+			t.Errorf("rendered HTML lacks %q:\n%s", want, out.HTML)
+		}
+	}
+	if len(out.TOC) != 2 || out.TOC[0].ID != "getting-started" || out.TOC[1].ID != "install-it" {
+		t.Errorf("TOC = %+v", out.TOC)
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/repository"
+	"github.com/tahsin005/codercat-server/utils"
+	"github.com/tahsin005/codercat-server/validation"
+)
+
+// PrivacyService implements data subject access and erasure requests for
+// subscribers, either authenticated by the subscriber's manage token or
+// triggered by an admin.
+type PrivacyService interface {
+	RequestAccess(ctx context.Context, email string) error
+	ExportByToken(ctx context.Context, token string) (*domain.SubscriberData, error)
+	EraseByToken(ctx context.Context, token string) error
+	ExportByID(ctx context.Context, id string) (*domain.SubscriberData, error)
+	EraseByID(ctx context.Context, id string) error
+}
+
+type privacyService struct {
+	subscriberRepo    repository.SubscriberRepository
+	sendRepo          repository.SendRepository
+	suppressionRepo   repository.SuppressionRepository
+	consentRepo       repository.ConsentRepository
+	mailService       MailService
+	templateService   TemplateService
+	baseURL           string
+	canonicalizeGmail bool
+	suppressionKey    []byte
+}
+
+func NewPrivacyService(subscriberRepo repository.SubscriberRepository, sendRepo repository.SendRepository, suppressionRepo repository.SuppressionRepository, consentRepo repository.ConsentRepository, mailService MailService, templateService TemplateService, baseURL string, canonicalizeGmail bool, suppressionKey []byte) PrivacyService {
+	return &privacyService{
+		subscriberRepo:    subscriberRepo,
+		sendRepo:          sendRepo,
+		suppressionRepo:   suppressionRepo,
+		consentRepo:       consentRepo,
+		mailService:       mailService,
+		templateService:   templateService,
+		baseURL:           baseURL,
+		canonicalizeGmail: canonicalizeGmail,
+		suppressionKey:    suppressionKey,
+	}
+}
+
+// RequestAccess emails links for downloading and erasing a subscriber's data
+// to the address itself, proving ownership. Unknown addresses are ignored
+// without error so the endpoint does not reveal who is subscribed.
+func (s *privacyService) RequestAccess(ctx context.Context, email string) error {
+	email = utils.NormalizeEmail(email)
+	if err := validation.ValidateSubscriber(&domain.Subscriber{Email: email}); err != nil {
+		return err
+	}
+	subscriber, err := s.subscriberRepo.FindByEmailKey(ctx, utils.CanonicalEmail(email, s.canonicalizeGmail))
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && subscriber.ManageToken == "") {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token := url.QueryEscape(subscriber.ManageToken)
+	data := domain.DataAccessEmailData{
+		Email:     subscriber.Email,
+		ExportURL: fmt.Sprintf("%s/privacy/data?token=%s", s.baseURL, token),
+		EraseURL:  fmt.Sprintf("%s/privacy/erase?token=%s", s.baseURL, token),
+	}
+	htmlBody, err := s.templateService.RenderEmailTemplate("data_access", data)
+	if err != nil {
+		return err
+	}
+	s.mailService.SendAsync(ctx, Mail{
+		Kind:       domain.SendDataAccess,
+		Subject:    "🐾 Your CoderCat data",
+		HTMLBody:   htmlBody,
+		Recipients: []*domain.Subscriber{subscriber},
+	})
+	return nil
+}
+
+func (s *privacyService) ExportByToken(ctx context.Context, token string) (*domain.SubscriberData, error) {
+	subscriber, err := s.byToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return s.export(ctx, subscriber)
+}
+
+func (s *privacyService) EraseByToken(ctx context.Context, token string) error {
+	subscriber, err := s.byToken(ctx, token)
+	if err != nil {
+		return err
+	}
+	return s.erase(ctx, subscriber, "subscriber request")
+}
+
+func (s *privacyService) ExportByID(ctx context.Context, id string) (*domain.SubscriberData, error) {
+	subscriber, err := s.byID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.export(ctx, subscriber)
+}
+
+func (s *privacyService) EraseByID(ctx context.Context, id string) error {
+	subscriber, err := s.byID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.erase(ctx, subscriber, "admin request")
+}
+
+func (s *privacyService) byToken(ctx context.Context, token string) (*domain.Subscriber, error) {
+	if token == "" {
+		return nil, domain.ErrNotFound
+	}
+	return s.subscriberRepo.FindByManageToken(ctx, token)
+}
+
+func (s *privacyService) byID(ctx context.Context, id string) (*domain.Subscriber, error) {
+	oid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.subscriberRepo.FindByID(ctx, oid)
+}
+
+func (s *privacyService) export(ctx context.Context, subscriber *domain.Subscriber) (*domain.SubscriberData, error) {
+	consents, err := s.consentRepo.FindBySubscriber(ctx, subscriber.ID)
+	if err != nil {
+		return nil, err
+	}
+	if consents == nil {
+		consents = []*domain.ConsentRecord{}
+	}
+	sends, err := s.sendRepo.FindBySubscriber(ctx, subscriber.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if sends == nil {
+		sends = []*domain.SendEvent{}
+	}
+	return &domain.SubscriberData{
+		ExportedAt:  time.Now().UTC(),
+		Email:       subscriber.Email,
+		EmailKey:    subscriber.EmailKey,
+		Status:      subscriber.Status,
+		CreatedAt:   subscriber.CreatedAt,
+		ConfirmedAt: subscriber.ConfirmedAt,
+		Consents:    consents,
+		Sends:       sends,
+	}, nil
+}
+
+// erase suppresses the address before deleting anything, so that a failure
+// part-way through can never leave the address mailable without a record.
+func (s *privacyService) erase(ctx context.Context, subscriber *domain.Subscriber, reason string) error {
+	if err := s.suppressionRepo.Add(ctx, utils.EmailHash(s.suppressionKey, subscriber.EmailKey), reason); err != nil {
+		return err
+	}
+	if err := s.sendRepo.DeleteBySubscriber(ctx, subscriber.ID); err != nil {
+		return err
+	}
+	if err := s.consentRepo.DeleteBySubscriber(ctx, subscriber.ID); err != nil {
+		return err
+	}
+	err := s.subscriberRepo.Delete(ctx, subscriber.ID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	return err
+}
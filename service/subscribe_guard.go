@@ -0,0 +1,252 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/logging"
+	"github.com/tahsin005/codercat-server/ratelimit"
+	"github.com/tahsin005/codercat-server/utils"
+	"github.com/tahsin005/codercat-server/validation"
+)
+
+//go:embed data/disposable_domains.txt
+var disposableDomainList []byte
+
+// ErrSilentlyRejected is returned for submissions that are almost certainly
+// automated. Handlers should respond as if the request succeeded so that
+// bots get no signal to adapt to.
+var ErrSilentlyRejected = errors.New("submission silently rejected")
+
+// SubscribeAttempt is an incoming subscription request as seen by the guard.
+type SubscribeAttempt struct {
+	Email     string
+	IP        string
+	Honeypot  string
+	Challenge string
+	Nonce     string
+}
+
+// Challenge is a proof-of-work puzzle. The client must find a nonce such
+// that SHA-256(challenge + ":" + nonce) starts with Difficulty zero bits.
+type Challenge struct {
+	Algorithm  string    `json:"algorithm"`
+	Challenge  string    `json:"challenge"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+type SubscribeGuardConfig struct {
+	IPLimit ratelimit.Limit
+	// DomainLimit is shared by everyone at a domain, gmail.com included,
+	// so it only suits short bursts aimed at one small domain.
+	DomainLimit     ratelimit.Limit
+	BlockDisposable bool
+	PowEnabled      bool
+	PowDifficulty   int
+	// PowSecret signs challenges. When empty a random secret is generated,
+	// which only works while a single instance serves both endpoints.
+	PowSecret []byte
+	PowTTL    time.Duration
+}
+
+// SubscribeGuard protects POST /subscribe from automated abuse.
+type SubscribeGuard interface {
+	Check(ctx context.Context, attempt SubscribeAttempt) error
+	NewChallenge() (*Challenge, error)
+}
+
+type subscribeGuard struct {
+	store      ratelimit.Store
+	cfg        SubscribeGuardConfig
+	disposable map[string]bool
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func NewSubscribeGuard(store ratelimit.Store, cfg SubscribeGuardConfig) (SubscribeGuard, error) {
+	if len(cfg.PowSecret) == 0 {
+		cfg.PowSecret = make([]byte, 32)
+		if _, err := rand.Read(cfg.PowSecret); err != nil {
+			return nil, err
+		}
+	}
+	return &subscribeGuard{
+		store:      store,
+		cfg:        cfg,
+		disposable: parseDomainList(disposableDomainList),
+		used:       make(map[string]time.Time),
+	}, nil
+}
+
+// Check runs the cheap checks first: the honeypot, then the proof of work,
+// then the per-IP and per-domain rate limits and finally the disposable
+// domain blocklist.
+func (g *subscribeGuard) Check(ctx context.Context, attempt SubscribeAttempt) error {
+	if attempt.Honeypot != "" {
+		return ErrSilentlyRejected
+	}
+	if g.cfg.PowEnabled {
+		if err := g.verifyChallenge(attempt.Challenge, attempt.Nonce); err != nil {
+			return err
+		}
+	}
+
+	if err := g.take(ctx, "subscribe:ip:"+attempt.IP, g.cfg.IPLimit); err != nil {
+		return err
+	}
+	domainName := emailDomain(attempt.Email)
+	if domainName == "" {
+		return nil
+	}
+	if err := g.take(ctx, "subscribe:domain:"+domainName, g.cfg.DomainLimit); err != nil {
+		return err
+	}
+	if g.cfg.BlockDisposable && g.isDisposable(domainName) {
+		return validation.Errors{{Field: "email", Message: "must not be a disposable email address"}}
+	}
+	return nil
+}
+
+// take fails open when the store is unavailable: a broken rate limiter
+// should not take subscriptions down with it.
+func (g *subscribeGuard) take(ctx context.Context, key string, limit ratelimit.Limit) error {
+	if limit.Burst <= 0 {
+		return nil
+	}
+	res, err := g.store.Take(ctx, key, limit)
+	if err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "rate limit store unavailable", "key", key, logging.Err(err))
+		return nil
+	}
+	if !res.Allowed {
+		return &domain.RateLimitError{RetryAfter: res.RetryAfter}
+	}
+	return nil
+}
+
+func (g *subscribeGuard) isDisposable(domainName string) bool {
+	for d := domainName; d != ""; {
+		if g.disposable[d] {
+			return true
+		}
+		_, parent, ok := strings.Cut(d, ".")
+		if !ok {
+			break
+		}
+		d = parent
+	}
+	return false
+}
+
+func (g *subscribeGuard) NewChallenge() (*Challenge, error) {
+	expires := time.Now().Add(g.cfg.PowTTL).UTC().Truncate(time.Second)
+	payload := make([]byte, 24)
+	binary.BigEndian.PutUint64(payload, uint64(expires.Unix()))
+	if _, err := rand.Read(payload[8:]); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(g.sign(payload))
+	return &Challenge{
+		Algorithm:  "sha256",
+		Challenge:  token,
+		Difficulty: g.cfg.PowDifficulty,
+		ExpiresAt:  expires,
+	}, nil
+}
+
+func (g *subscribeGuard) verifyChallenge(challenge, nonce string) error {
+	invalid := func(msg string) error {
+		return validation.Errors{{Field: "challenge", Message: msg}}
+	}
+	if challenge == "" || nonce == "" {
+		return invalid("a solved proof-of-work challenge is required")
+	}
+	if len(nonce) > 64 {
+		return invalid("nonce is too long")
+	}
+	payloadStr, sigStr, ok := strings.Cut(challenge, ".")
+	payload, err1 := base64.RawURLEncoding.DecodeString(payloadStr)
+	sig, err2 := base64.RawURLEncoding.DecodeString(sigStr)
+	if !ok || err1 != nil || err2 != nil || len(payload) != 24 || !hmac.Equal(sig, g.sign(payload)) {
+		return invalid("is not a valid challenge")
+	}
+	expires := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	now := time.Now()
+	if now.After(expires) {
+		return invalid("has expired")
+	}
+
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	if leadingZeroBits(sum[:]) < g.cfg.PowDifficulty {
+		return invalid("nonce does not solve the challenge")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for c, exp := range g.used {
+		if now.After(exp) {
+			delete(g.used, c)
+		}
+	}
+	if _, seen := g.used[challenge]; seen {
+		return invalid("has already been used")
+	}
+	g.used[challenge] = expires
+	return nil
+}
+
+func (g *subscribeGuard) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, g.cfg.PowSecret)
+	mac.Write(payload)
+	return mac.Sum(nil)[:16]
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, x := range b {
+		if x != 0 {
+			return n + bits.LeadingZeros8(x)
+		}
+		n += 8
+	}
+	return n
+}
+
+// emailDomain returns the lower-cased domain of email, or "" if it has
+// none.
+func emailDomain(email string) string {
+	email = utils.NormalizeEmail(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return email[at+1:]
+}
+
+func parseDomainList(data []byte) map[string]bool {
+	domains := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains[line] = true
+	}
+	return domains
+}
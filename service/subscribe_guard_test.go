@@ -0,0 +1,24 @@
+package service
+
+import "testing"
+
+func TestEmailDomain(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"ada@example.com", "example.com"},
+		{"Ada@Example.COM", "example.com"},
+		{"     a@b", "b"},
+		{" x@mailinator.com", "mailinator.com"},
+		{"\tx@MailInator.com \n", "mailinator.com"},
+		{"odd@name@example.org", "example.org"},
+		{"  no-at-sign  ", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := emailDomain(tt.email); got != tt.want {
+			t.Errorf("emailDomain(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
@@ -2,28 +2,424 @@ package service
 
 import (
 	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/tahsin005/codercat-server/domain"
 	"github.com/tahsin005/codercat-server/repository"
+	"github.com/tahsin005/codercat-server/utils"
+	"github.com/tahsin005/codercat-server/validation"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type SubscriberService interface {
-	CreateSubscriber(ctx context.Context, subscriber *domain.Subscriber) error
+	Subscribe(ctx context.Context, email string, consent domain.ConsentContext) error
+	Confirm(ctx context.Context, token string, consent domain.ConsentContext) error
 	GetAll(ctx context.Context) ([]*domain.Subscriber, error)
+	GetConfirmed(ctx context.Context) ([]*domain.Subscriber, error)
+	ListSubscribers(ctx context.Context, filter domain.SubscriberFilter) ([]*domain.Subscriber, int64, error)
+	GetSubscriber(ctx context.Context, id string) (*domain.SubscriberDetail, error)
+	RemoveSubscriber(ctx context.Context, id string, consent domain.ConsentContext) error
+	ExportSubscribers(ctx context.Context, filter domain.SubscriberFilter) ([]*domain.SubscriberExport, error)
+	ImportSubscribers(ctx context.Context, r io.Reader, dryRun bool, consent domain.ConsentContext) (*domain.ImportReport, error)
 }
 
+// sendHistoryLimit caps the number of sends shown on the subscriber view.
+const sendHistoryLimit = 50
+
 type subscriberService struct {
-	repo repository.SubscriberRepository
+	repo            repository.SubscriberRepository
+	sendRepo        repository.SendRepository
+	suppressionRepo repository.SuppressionRepository
+	consentRepo     repository.ConsentRepository
+	mailService     MailService
+	templateService TemplateService
+	baseURL         string
+	// consentTextVersions lists the current consent text version first,
+	// then earlier ones still accepted from clients.
+	consentTextVersions []string
+	canonicalizeGmail   bool
+	suppressionKey      []byte
+}
+
+func NewSubscriberService(repo repository.SubscriberRepository, sendRepo repository.SendRepository, suppressionRepo repository.SuppressionRepository, consentRepo repository.ConsentRepository, mailService MailService, templateService TemplateService, baseURL string, consentTextVersions []string, canonicalizeGmail bool, suppressionKey []byte) SubscriberService {
+	return &subscriberService{
+		repo:                repo,
+		sendRepo:            sendRepo,
+		suppressionRepo:     suppressionRepo,
+		consentRepo:         consentRepo,
+		mailService:         mailService,
+		templateService:     templateService,
+		baseURL:             baseURL,
+		consentTextVersions: consentTextVersions,
+		canonicalizeGmail:   canonicalizeGmail,
+		suppressionKey:      suppressionKey,
+	}
 }
 
-func NewSubscriberService(repo repository.SubscriberRepository) SubscriberService {
-	return &subscriberService{repo: repo}
+// Subscribe starts a double opt-in subscription for email. It is idempotent:
+// subscribing an address that is still pending re-sends the confirmation,
+// and subscribing one that is already confirmed does nothing. Callers should
+// respond identically in every case so the result does not reveal whether
+// the address was already on the list. A consent text version given by the
+// client must be one the server knows. Calls that create a subscription or
+// re-send the confirmation are added to the consent log. An address
+// suppressed after an erasure request can subscribe again in the same way,
+// since only a confirmation from its inbox lifts the suppression.
+func (s *subscriberService) Subscribe(ctx context.Context, email string, consent domain.ConsentContext) error {
+	subscriber := &domain.Subscriber{Email: utils.NormalizeEmail(email)}
+	if err := validation.ValidateSubscriber(subscriber); err != nil {
+		return err
+	}
+	if consent.TextVersion != "" && !slices.Contains(s.consentTextVersions, consent.TextVersion) {
+		return validation.Errors{{Field: "consentVersion", Message: "is not a known consent text version"}}
+	}
+	subscriber.EmailKey = utils.CanonicalEmail(subscriber.Email, s.canonicalizeGmail)
+
+	existing, err := s.repo.FindByEmailKey(ctx, subscriber.EmailKey)
+	switch {
+	case err == nil:
+		if existing.Status != domain.SubscriberPending {
+			return nil
+		}
+		if err := s.recordConsent(ctx, existing.ID, domain.ConsentSubscribe, consent); err != nil {
+			return err
+		}
+		return s.sendConfirmation(ctx, existing)
+	case !errors.Is(err, domain.ErrNotFound):
+		return err
+	}
+
+	if subscriber.ConfirmToken, err = utils.NewToken(); err != nil {
+		return err
+	}
+	if subscriber.ManageToken, err = utils.NewToken(); err != nil {
+		return err
+	}
+	subscriber.Status = domain.SubscriberPending
+	if err := s.repo.CreateSubscriber(ctx, subscriber); err != nil {
+		// A concurrent request subscribed the same address first.
+		if errors.Is(err, domain.ErrConflict) {
+			return nil
+		}
+		return err
+	}
+	if err := s.recordConsent(ctx, subscriber.ID, domain.ConsentSubscribe, consent); err != nil {
+		return err
+	}
+	return s.sendConfirmation(ctx, subscriber)
 }
 
-func (s *subscriberService) CreateSubscriber(ctx context.Context, subscriber *domain.Subscriber) error {
-	return s.repo.CreateSubscriber(ctx, subscriber)
+func (s *subscriberService) Confirm(ctx context.Context, token string, consent domain.ConsentContext) error {
+	if token == "" {
+		return domain.ErrNotFound
+	}
+	subscriber, err := s.repo.FindByConfirmToken(ctx, token)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Confirm(ctx, subscriber.ID, time.Now().UTC()); err != nil {
+		return err
+	}
+	if err := s.recordConsent(ctx, subscriber.ID, domain.ConsentConfirm, consent); err != nil {
+		return err
+	}
+	// Confirming from the inbox is an explicit request to be mailed again,
+	// so it lifts any suppression left by an earlier erasure.
+	return s.suppressionRepo.Remove(ctx, utils.EmailHash(s.suppressionKey, subscriber.EmailKey))
 }
 
 func (s *subscriberService) GetAll(ctx context.Context) ([]*domain.Subscriber, error) {
 	return s.repo.GetAll(ctx)
 }
+
+// GetConfirmed returns the subscribers a newsletter goes to: confirmed ones
+// whose address has not been suppressed.
+func (s *subscriberService) GetConfirmed(ctx context.Context) ([]*domain.Subscriber, error) {
+	subscribers, err := s.repo.FindByStatus(ctx, domain.SubscriberConfirmed)
+	if err != nil || len(subscribers) == 0 {
+		return subscribers, err
+	}
+	hashes := make([]string, len(subscribers))
+	for i, sub := range subscribers {
+		hashes[i] = utils.EmailHash(s.suppressionKey, sub.EmailKey)
+	}
+	suppressedHashes, err := s.suppressionRepo.FindExisting(ctx, hashes)
+	if err != nil || len(suppressedHashes) == 0 {
+		return subscribers, err
+	}
+	suppressed := make(map[string]bool, len(suppressedHashes))
+	for _, h := range suppressedHashes {
+		suppressed[h] = true
+	}
+	mailable := subscribers[:0]
+	for i, sub := range subscribers {
+		if !suppressed[hashes[i]] {
+			mailable = append(mailable, sub)
+		}
+	}
+	return mailable, nil
+}
+
+func (s *subscriberService) sendConfirmation(ctx context.Context, subscriber *domain.Subscriber) error {
+	data := domain.ConfirmEmailData{
+		Email:      subscriber.Email,
+		ConfirmURL: fmt.Sprintf("%s/subscribe/confirm?token=%s", s.baseURL, url.QueryEscape(subscriber.ConfirmToken)),
+	}
+	htmlBody, err := s.templateService.RenderEmailTemplate("confirm_subscription", data)
+	if err != nil {
+		return err
+	}
+
+	s.mailService.SendAsync(ctx, Mail{
+		Kind:       domain.SendConfirmation,
+		Subject:    "🐾 Confirm your CoderCat subscription",
+		HTMLBody:   htmlBody,
+		Recipients: []*domain.Subscriber{subscriber},
+	})
+	return nil
+}
+
+func (s *subscriberService) ListSubscribers(ctx context.Context, filter domain.SubscriberFilter) ([]*domain.Subscriber, int64, error) {
+	return s.repo.List(ctx, filter)
+}
+
+func (s *subscriberService) GetSubscriber(ctx context.Context, id string) (*domain.SubscriberDetail, error) {
+	oid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	subscriber, err := s.repo.FindByID(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	consents, err := s.consentRepo.FindBySubscriber(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	sends, err := s.sendRepo.FindBySubscriber(ctx, oid, sendHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.SubscriberDetail{Subscriber: subscriber, Consents: consents, Sends: sends}, nil
+}
+
+// ExportSubscribers returns every subscriber matching filter together with
+// their consent log.
+func (s *subscriberService) ExportSubscribers(ctx context.Context, filter domain.SubscriberFilter) ([]*domain.SubscriberExport, error) {
+	filter.Skip, filter.Limit = 0, 0
+	subscribers, _, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]bson.ObjectID, len(subscribers))
+	for i, sub := range subscribers {
+		ids[i] = sub.ID
+	}
+	consents, err := s.consentRepo.FindBySubscribers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	bySubscriber := make(map[bson.ObjectID][]*domain.ConsentRecord)
+	for _, c := range consents {
+		bySubscriber[c.SubscriberID] = append(bySubscriber[c.SubscriberID], c)
+	}
+
+	exports := make([]*domain.SubscriberExport, len(subscribers))
+	for i, sub := range subscribers {
+		records := bySubscriber[sub.ID]
+		if records == nil {
+			records = []*domain.ConsentRecord{}
+		}
+		exports[i] = &domain.SubscriberExport{Subscriber: sub, Consents: records}
+	}
+	return exports, nil
+}
+
+// RemoveSubscriber deletes a subscriber with their send history. Their
+// consent log is kept, ending with a remove entry describing the admin
+// request.
+func (s *subscriberService) RemoveSubscriber(ctx context.Context, id string, consent domain.ConsentContext) error {
+	oid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, oid); err != nil {
+		return err
+	}
+	if err := s.recordConsent(ctx, oid, domain.ConsentRemove, consent); err != nil {
+		return err
+	}
+	return s.sendRepo.DeleteBySubscriber(ctx, oid)
+}
+
+// ImportSubscribers reads subscribers from CSV with a header row containing
+// an "email" column and an optional "status" column. Rows are imported as
+// pending, and go through double opt-in, unless their status is explicitly
+// confirmed. Invalid rows, addresses repeated within the file, addresses
+// already subscribed and addresses suppressed after an erasure request are
+// reported and skipped. With dryRun nothing is written or sent. Imported
+// subscribers get an import entry in the consent log describing the admin
+// request, and pending ones are sent a confirmation email.
+func (s *subscriberService) ImportSubscribers(ctx context.Context, r io.Reader, dryRun bool, consent domain.ConsentContext) (*domain.ImportReport, error) {
+	rows, err := csvRecords(r)
+	if err != nil {
+		return nil, err
+	}
+	report := &domain.ImportReport{DryRun: dryRun, Invalid: []domain.ImportIssue{}}
+	if len(rows) == 0 {
+		return report, nil
+	}
+	emailCol, statusCol := -1, -1
+	for i, name := range rows[0] {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "email":
+			emailCol = i
+		case "status":
+			statusCol = i
+		}
+	}
+	if emailCol < 0 {
+		return nil, validation.Errors{{Field: "file", Message: "must have a header row with an email column"}}
+	}
+
+	var candidates []*domain.Subscriber
+	seen := make(map[string]bool)
+	for i, row := range rows[1:] {
+		line := i + 2
+		report.Total++
+
+		subscriber := &domain.Subscriber{Status: domain.SubscriberPending}
+		if emailCol < len(row) {
+			subscriber.Email = utils.NormalizeEmail(row[emailCol])
+		}
+		if statusCol >= 0 && statusCol < len(row) && strings.TrimSpace(row[statusCol]) != "" {
+			subscriber.Status = domain.SubscriberStatus(strings.ToLower(strings.TrimSpace(row[statusCol])))
+		}
+
+		if err := validation.ValidateSubscriber(subscriber); err != nil {
+			report.Invalid = append(report.Invalid, domain.ImportIssue{Line: line, Email: subscriber.Email, Reason: issueReason(err)})
+			continue
+		}
+		subscriber.EmailKey = utils.CanonicalEmail(subscriber.Email, s.canonicalizeGmail)
+		if seen[subscriber.EmailKey] {
+			report.Duplicates++
+			continue
+		}
+		seen[subscriber.EmailKey] = true
+		candidates = append(candidates, subscriber)
+	}
+
+	keys := make([]string, len(candidates))
+	hashes := make([]string, len(candidates))
+	for i, c := range candidates {
+		keys[i] = c.EmailKey
+		hashes[i] = utils.EmailHash(s.suppressionKey, c.EmailKey)
+	}
+	existing, err := s.repo.FindByEmailKeys(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, e := range existing {
+		known[e.EmailKey] = true
+	}
+	suppressedHashes, err := s.suppressionRepo.FindExisting(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+	suppressed := make(map[string]bool, len(suppressedHashes))
+	for _, h := range suppressedHashes {
+		suppressed[h] = true
+	}
+
+	now := time.Now().UTC()
+	for i, c := range candidates {
+		if known[c.EmailKey] {
+			report.Existing++
+			continue
+		}
+		if suppressed[hashes[i]] {
+			report.Suppressed++
+			continue
+		}
+		if dryRun {
+			report.Imported++
+			continue
+		}
+		if c.Status == domain.SubscriberConfirmed {
+			c.ConfirmedAt = &now
+		} else if c.ConfirmToken, err = utils.NewToken(); err != nil {
+			return nil, err
+		}
+		if c.ManageToken, err = utils.NewToken(); err != nil {
+			return nil, err
+		}
+		if err := s.repo.CreateSubscriber(ctx, c); err != nil {
+			if errors.Is(err, domain.ErrConflict) {
+				report.Existing++
+				continue
+			}
+			return report, err
+		}
+		if err := s.recordConsent(ctx, c.ID, domain.ConsentImport, consent); err != nil {
+			return report, err
+		}
+		if c.Status == domain.SubscriberPending {
+			if err := s.sendConfirmation(ctx, c); err != nil {
+				return report, err
+			}
+		}
+		report.Imported++
+	}
+	return report, nil
+}
+
+// recordConsent appends an entry to the consent log. The consent text
+// version defaults to the current one.
+func (s *subscriberService) recordConsent(ctx context.Context, subscriberID bson.ObjectID, action domain.ConsentAction, consent domain.ConsentContext) error {
+	version := consent.TextVersion
+	if version == "" {
+		version = s.consentTextVersions[0]
+	}
+	return s.consentRepo.Create(ctx, &domain.ConsentRecord{
+		SubscriberID: subscriberID,
+		Action:       action,
+		Source:       consent.Source,
+		IP:           consent.IP,
+		UserAgent:    consent.UserAgent,
+		TextVersion:  version,
+		CreatedAt:    time.Now().UTC(),
+	})
+}
+
+func issueReason(err error) string {
+	var fieldErrors validation.Errors
+	if !errors.As(err, &fieldErrors) {
+		return err.Error()
+	}
+	reasons := make([]string, len(fieldErrors))
+	for i, fe := range fieldErrors {
+		reasons[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(reasons, "; ")
+}
+
+func csvRecords(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, validation.Errors{{Field: "file", Message: "is not valid CSV: " + err.Error()}}
+	}
+	// Other errors come from reading the body, such as it being too large.
+	return rows, err
+}
@@ -2,12 +2,18 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"html/template"
 	"path/filepath"
 )
 
 type TemplateService interface {
 	RenderEmailTemplate(templateName string, data interface{}) (string, error)
+	RenderPageTemplate(templateName string, data interface{}) (string, error)
+	// Check parses every template so that missing or broken files are
+	// noticed before someone tries to send them.
+	Check(ctx context.Context) error
 }
 
 type templateService struct {
@@ -21,8 +27,35 @@ func NewTemplateService(templatesDir string) TemplateService {
 }
 
 func (s *templateService) RenderEmailTemplate(templateName string, data interface{}) (string, error) {
-	templatePath := filepath.Join(s.templatesDir, "email", templateName+".html")
+	return s.render(filepath.Join(s.templatesDir, "email", templateName+".html"), data)
+}
+
+// RenderPageTemplate renders one of the small standalone HTML pages served
+// to subscribers following links from their inbox.
+func (s *templateService) RenderPageTemplate(templateName string, data interface{}) (string, error) {
+	return s.render(filepath.Join(s.templatesDir, "pages", templateName+".html"), data)
+}
+
+func (s *templateService) Check(ctx context.Context) error {
+	for _, dir := range []string{"email", "pages"} {
+		pattern := filepath.Join(s.templatesDir, dir, "*.html")
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no templates found matching %s", pattern)
+		}
+		for _, file := range files {
+			if _, err := template.ParseFiles(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
 
+func (s *templateService) render(templatePath string, data interface{}) (string, error) {
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
 		return "", err
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/tahsin005/codercat-server/domain"
+	"github.com/tahsin005/codercat-server/tracing"
+)
+
+type tracedBlogService struct {
+	next BlogService
+}
+
+// NewTracedBlogService wraps every BlogService call in a span, so traces
+// show how long each request spends in the service and below.
+func NewTracedBlogService(next BlogService) BlogService {
+	return &tracedBlogService{next: next}
+}
+
+func (s *tracedBlogService) CreateBlog(ctx context.Context, blog *domain.Blog) error {
+	ctx, span := tracing.Start(ctx, "BlogService.CreateBlog")
+	err := s.next.CreateBlog(ctx, blog)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedBlogService) GetBlogByID(ctx context.Context, id string) (*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.GetBlogByID")
+	v, err := s.next.GetBlogByID(ctx, id)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (s *tracedBlogService) UpdateBlog(ctx context.Context, id string, blog *domain.Blog) error {
+	ctx, span := tracing.Start(ctx, "BlogService.UpdateBlog")
+	err := s.next.UpdateBlog(ctx, id, blog)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedBlogService) DeleteBlog(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "BlogService.DeleteBlog")
+	err := s.next.DeleteBlog(ctx, id)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedBlogService) GetAllBlogs(ctx context.Context) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.GetAllBlogs")
+	v, err := s.next.GetAllBlogs(ctx)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (s *tracedBlogService) GetFeaturedBlogs(ctx context.Context) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.GetFeaturedBlogs")
+	v, err := s.next.GetFeaturedBlogs(ctx)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (s *tracedBlogService) GetRecentBlogs(ctx context.Context, limit int) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.GetRecentBlogs")
+	v, err := s.next.GetRecentBlogs(ctx, limit)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (s *tracedBlogService) GetBlogsByCategory(ctx context.Context, category string) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.GetBlogsByCategory")
+	v, err := s.next.GetBlogsByCategory(ctx, category)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (s *tracedBlogService) SearchBlogs(ctx context.Context, query string) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.SearchBlogs")
+	v, err := s.next.SearchBlogs(ctx, query)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (s *tracedBlogService) GetRelatedBlogs(ctx context.Context, id string, limit int) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.GetRelatedBlogs")
+	v, err := s.next.GetRelatedBlogs(ctx, id, limit)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (s *tracedBlogService) GetCategories(ctx context.Context) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.GetCategories")
+	v, err := s.next.GetCategories(ctx)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (s *tracedBlogService) GetPopularCategories(ctx context.Context, limit int) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.GetPopularCategories")
+	v, err := s.next.GetPopularCategories(ctx, limit)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (s *tracedBlogService) GetTrashedBlogs(ctx context.Context) ([]*domain.Blog, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.GetTrashedBlogs")
+	v, err := s.next.GetTrashedBlogs(ctx)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (s *tracedBlogService) RestoreBlog(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "BlogService.RestoreBlog")
+	err := s.next.RestoreBlog(ctx, id)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedBlogService) PurgeBlog(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "BlogService.PurgeBlog")
+	err := s.next.PurgeBlog(ctx, id)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedBlogService) PurgeExpiredTrash(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.PurgeExpiredTrash")
+	v, err := s.next.PurgeExpiredTrash(ctx)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (s *tracedBlogService) GetLastModified(ctx context.Context) (time.Time, error) {
+	ctx, span := tracing.Start(ctx, "BlogService.GetLastModified")
+	v, err := s.next.GetLastModified(ctx)
+	tracing.End(span, err)
+	return v, err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tahsin005/codercat-server/config"
+	"github.com/tahsin005/codercat-server/database"
+	"github.com/tahsin005/codercat-server/migration"
+	"github.com/tahsin005/codercat-server/repository"
+)
+
+// storage is the backend selected by DATABASE_DRIVER: its repositories,
+// migrations and connection.
+type storage struct {
+	blogs        repository.BlogRepository
+	subscribers  repository.SubscriberRepository
+	sends        repository.SendRepository
+	suppressions repository.SuppressionRepository
+	consents     repository.ConsentRepository
+	migrator     migration.Migrator
+
+	// mongo is set on the Mongo backend only, for the features that still
+	// need it directly.
+	mongo *database.Database
+	ping  func(ctx context.Context) error
+	close func()
+}
+
+func openStorage(cfg *config.Config) (*storage, error) {
+	if cfg.DatabaseDriver == "mongo" {
+		db, err := database.NewDatabase(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to MongoDB Atlas: %w", err)
+		}
+		slog.Info("connected to MongoDB Atlas")
+		return &storage{
+			blogs:        repository.NewBlogRepository(db, cfg),
+			subscribers:  repository.NewSubscriberRepository(db, cfg),
+			sends:        repository.NewSendRepository(db, cfg),
+			suppressions: repository.NewSuppressionRepository(db, cfg),
+			consents:     repository.NewConsentRepository(db, cfg),
+			migrator:     migration.NewRunner(db.DB, migration.All(cfg)),
+			mongo:        db,
+			ping:         db.Ping,
+			close: func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				db.Disconnect(ctx)
+				slog.Info("disconnected from MongoDB Atlas")
+			},
+		}, nil
+	}
+
+	db, err := database.NewSQLDatabase(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", cfg.DatabaseDriver, err)
+	}
+	slog.Info("connected to the database", "driver", cfg.DatabaseDriver)
+	return &storage{
+		blogs:        repository.NewSQLBlogRepository(db),
+		subscribers:  repository.NewSQLSubscriberRepository(db),
+		sends:        repository.NewSQLSendRepository(db),
+		suppressions: repository.NewSQLSuppressionRepository(db),
+		consents:     repository.NewSQLConsentRepository(db),
+		migrator:     migration.NewSQLRunner(db),
+		ping:         db.Ping,
+		close: func() {
+			db.Close()
+			slog.Info("disconnected from the database", "driver", cfg.DatabaseDriver)
+		},
+	}, nil
+}
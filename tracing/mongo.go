@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CommandMonitor returns a Mongo command monitor that wraps every command
+// in a client span. Only the command and collection names are recorded;
+// command bodies can hold subscriber email addresses.
+func CommandMonitor() *event.CommandMonitor {
+	var spans sync.Map // request ID -> trace.Span
+
+	finish := func(requestID int64, err error) {
+		if span, ok := spans.LoadAndDelete(requestID); ok {
+			End(span.(trace.Span), err)
+		}
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			name := evt.CommandName
+			collection := commandCollection(evt)
+			if collection != "" {
+				name += " " + collection
+			}
+			_, span := Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemNameMongoDB,
+					semconv.DBNamespace(evt.DatabaseName),
+					semconv.DBOperationName(evt.CommandName),
+					semconv.DBCollectionName(collection),
+				),
+			)
+			spans.Store(evt.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			finish(evt.RequestID, nil)
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			finish(evt.RequestID, evt.Failure)
+		},
+	}
+}
+
+// commandCollection returns the collection a command targets, which by
+// convention is the value of its first element.
+func commandCollection(evt *event.CommandStartedEvent) string {
+	elems, err := evt.Command.Elements()
+	if err != nil || len(elems) == 0 {
+		return ""
+	}
+	if name, ok := elems[0].Value().StringValueOK(); ok {
+		return name
+	}
+	return ""
+}
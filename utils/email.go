@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/smtp"
 )
 
@@ -36,3 +38,22 @@ func SendHTMLEmail(config EmailConfig, to []string, subject, htmlBody string) er
 	addr := fmt.Sprintf("%s:%s", config.SMTPHost, config.SMTPPort)
 	return smtp.SendMail(addr, auth, config.From, to, msg)
 }
+
+// CheckSMTP connects to the SMTP server and waits for its greeting without
+// sending anything.
+func CheckSMTP(ctx context.Context, config EmailConfig) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(config.SMTPHost, config.SMTPPort))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, config.SMTPHost)
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
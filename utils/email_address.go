@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// NormalizeEmail trims surrounding whitespace and lower-cases the domain.
+// The local part is left untouched because it is case-sensitive per RFC 5321,
+// even though almost no provider treats it that way.
+func NormalizeEmail(email string) string {
+	email = strings.TrimSpace(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	return email[:at+1] + strings.ToLower(email[at+1:])
+}
+
+// CanonicalEmail returns the key used to decide whether two addresses
+// belong to the same mailbox. The whole address is lower-cased and, when
+// gmail is true, Gmail dots and "+tag" suffixes are removed and googlemail.com
+// is folded into gmail.com.
+func CanonicalEmail(email string, gmail bool) string {
+	email = strings.ToLower(NormalizeEmail(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 || !gmail {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if domain != "gmail.com" && domain != "googlemail.com" {
+		return email
+	}
+	if plus := strings.IndexByte(local, '+'); plus >= 0 {
+		local = local[:plus]
+	}
+	return strings.ReplaceAll(local, ".", "") + "@gmail.com"
+}
+
+// EmailHash returns the hex HMAC-SHA256 of a canonical email under key,
+// used to remember erased addresses without storing them. Without the key,
+// guessed addresses cannot be checked against the stored hashes. The hash
+// depends on the canonical form, so changing how addresses are
+// canonicalized stops earlier hashes from matching.
+func EmailHash(key []byte, emailKey string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(emailKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,146 @@
+package validation
+
+import (
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tahsin005/codercat-server/domain"
+)
+
+const (
+	MaxTitleLength    = 200
+	MaxExcerptLength  = 500
+	MaxAuthorLength   = 100
+	MaxCategoryLength = 50
+	MaxURLLength      = 2048
+	MaxTags           = 10
+	MaxTagLength      = 30
+	MaxEmailLength    = 254
+)
+
+// FieldError describes a single invalid field in a request payload.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors collects every FieldError found while validating a value.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *Errors) add(field, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// err returns nil when no field errors were recorded so callers can use the
+// usual `if err != nil` check.
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func ValidateBlog(blog *domain.Blog) error {
+	var errs Errors
+
+	requiredString(&errs, "title", blog.Title, MaxTitleLength)
+	requiredString(&errs, "author", blog.Author, MaxAuthorLength)
+	requiredString(&errs, "category", blog.Category, MaxCategoryLength)
+	if strings.TrimSpace(blog.Content) == "" {
+		errs.add("content", "is required")
+	}
+	if utf8.RuneCountInString(blog.Excerpt) > MaxExcerptLength {
+		errs.add("excerpt", tooLong(MaxExcerptLength))
+	}
+	optionalURL(&errs, "image", blog.Image)
+	optionalURL(&errs, "authorImage", blog.AuthorImage)
+
+	if len(blog.Tags) > MaxTags {
+		errs.add("tags", "must contain at most "+strconv.Itoa(MaxTags)+" tags")
+	}
+	seen := make(map[string]bool, len(blog.Tags))
+	for _, tag := range blog.Tags {
+		key := strings.ToLower(strings.TrimSpace(tag))
+		switch {
+		case key == "":
+			errs.add("tags", "must not contain empty tags")
+		case utf8.RuneCountInString(tag) > MaxTagLength:
+			errs.add("tags", "tag \""+tag+"\" "+tooLong(MaxTagLength))
+		case seen[key]:
+			errs.add("tags", "tag \""+tag+"\" is duplicated")
+		}
+		seen[key] = true
+	}
+
+	return errs.err()
+}
+
+func ValidateSubscriber(subscriber *domain.Subscriber) error {
+	var errs Errors
+	if msg := emailProblem(subscriber.Email); msg != "" {
+		errs.add("email", msg)
+	}
+	switch subscriber.Status {
+	case "", domain.SubscriberPending, domain.SubscriberConfirmed:
+	default:
+		errs.add("status", "must be pending or confirmed")
+	}
+	return errs.err()
+}
+
+func emailProblem(email string) string {
+	if strings.TrimSpace(email) == "" {
+		return "is required"
+	}
+	if len(email) > MaxEmailLength {
+		return tooLong(MaxEmailLength)
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "must be a valid email address"
+	}
+	at := strings.LastIndex(email, "@")
+	if !strings.Contains(email[at+1:], ".") {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+func requiredString(errs *Errors, field, value string, max int) {
+	if strings.TrimSpace(value) == "" {
+		errs.add(field, "is required")
+		return
+	}
+	if utf8.RuneCountInString(value) > max {
+		errs.add(field, tooLong(max))
+	}
+}
+
+func optionalURL(errs *Errors, field, value string) {
+	if value == "" {
+		return
+	}
+	if len(value) > MaxURLLength {
+		errs.add(field, tooLong(MaxURLLength))
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add(field, "must be an absolute http or https URL")
+	}
+}
+
+func tooLong(max int) string {
+	return "must be at most " + strconv.Itoa(max) + " characters"
+}